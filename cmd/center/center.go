@@ -1,17 +1,20 @@
 package main
 
 import (
+	"BlankZhu/wakizashi/pkg/auth"
 	"BlankZhu/wakizashi/pkg/backend"
+	"BlankZhu/wakizashi/pkg/cert"
 	"BlankZhu/wakizashi/pkg/config"
 	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/device"
 	liveprobe "BlankZhu/wakizashi/pkg/probe"
-	"BlankZhu/wakizashi/pkg/recovery"
 	"BlankZhu/wakizashi/pkg/transmit"
 	"BlankZhu/wakizashi/pkg/util"
+	"context"
 	"flag"
 	"fmt"
 	"net"
+	"os"
 	"path"
 	"strconv"
 	"time"
@@ -46,12 +49,40 @@ func launchHealthProbe(port uint16, fin chan<- struct{}) {
 	close(fin)
 }
 
+// migrateRecovery moves the recovery files spooled directly in recovDir by former versions with a single backend
+// to recovDir/[name], so that they are reposted to the backend
+func migrateRecovery(recovDir, name string) error {
+	for _, file := range []string{constant.RecoveryDefaultFileName, constant.RecoveryDefaultPosName} {
+		from := path.Join(recovDir, file)
+		to := path.Join(recovDir, name, file)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			return fmt.Errorf("both %s and %s exist, remove one of them", from, to)
+		}
+		if err := os.MkdirAll(path.Join(recovDir, name), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		logrus.Infof("recovery file %s moved to %s", from, to)
+	}
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		token(os.Args[2:])
+		return
+	}
+
 	cfgPathPtr := flag.String("c", constant.CenterDefaultConfigPath, "path to center's config yaml file")
 	verPtr := flag.Bool("v", false, "print version info")
 	flag.Parse()
 
-	fmt.Println(title)
+	fmt.Print(title)
 	fmt.Printf("Build time: %s\nBuild version: %s\nGit commit ID: %s\n", buildTime, buildVersion, gitCommitID)
 	if *verPtr {
 		return
@@ -78,34 +109,36 @@ func main() {
 	}
 	ips := util.GetIPSetFromNetworkInterfaces(devs)
 
-	// initialized data backend client
-	backend.Init(conf.BackendConfig)
-	cli := backend.Get()
-	if cli == nil {
-		logrus.Fatalf("failed to get data backend client (nil)")
+	// initialized data backend clients, each with its own recovery in recovDir/[name]
+	if err := migrateRecovery(conf.RecovDir, conf.BackendConfigs[0].Name); err != nil {
+		logrus.Fatalf("failed to migrate recovery files to data backend %s, detail: %s", conf.BackendConfigs[0].Name, err)
 	}
-	err = (*cli).Connect()
-	if err != nil {
+	fanout := &backend.Fanout{}
+	for _, bcfg := range conf.BackendConfigs {
+		cli, err := backend.New(bcfg)
+		if err != nil {
+			logrus.Fatalf("failed to create data backend client %s, detail: %s", bcfg.Name, err)
+		}
+		sink, err := backend.NewSink(bcfg.Name, cli, path.Join(conf.RecovDir, bcfg.Name))
+		if err != nil {
+			logrus.Fatalf("failed to setup recovery for data backend %s, detail: %s", bcfg.Name, err)
+		}
+		fanout.Sinks = append(fanout.Sinks, sink)
+	}
+	if err := fanout.Connect(); err != nil {
 		logrus.Fatalf("failed to connect to database, detail: %s", err)
 	}
-	defer (*cli).Close()
+	defer fanout.Close()
 
 	// setup recovery
-	r := recovery.Get()
-	recoveryPath := path.Join(conf.RecovDir, constant.RecoveryDefaultFileName)
-	positionPath := path.Join(conf.RecovDir, constant.RecoveryDefaultPosName)
-	r.Init(recoveryPath,
-		positionPath,
-		constant.RecoveryDefaultPosLimit,
-		constant.RecoveryDefaultCacheSize,
-		(*cli).Write,
-	)
-	go func() {
-		for {
-			<-time.After(time.Duration(conf.RecovInterval) * time.Second)
-			r.RepostRecord()
-		}
-	}()
+	for _, sink := range fanout.Sinks {
+		go func(sink *backend.Sink) {
+			for {
+				<-time.After(time.Duration(conf.RecovInterval) * time.Second)
+				sink.RepostRecord()
+			}
+		}(sink)
+	}
 
 	// setup health probe
 	fin := make(chan struct{}, 1)
@@ -117,8 +150,29 @@ func main() {
 		logrus.Fatalf("failed to listen on port %d, detail: %s", conf.Port, err)
 	}
 	logrus.Infof("wakizashi center listening on port %d", conf.Port)
-	serv := grpc.NewServer()
-	transmit.RegisterTransmitServer(serv, &transmit.CenterServer{IPSet: ips})
+	opts := make([]grpc.ServerOption, 0)
+	if conf.TLS.Enabled {
+		r, err := cert.NewReloader(conf.TLS, cert.Server)
+		if err != nil {
+			logrus.Fatalf("failed to load TLS certificates, detail: %s", err)
+		}
+		go r.Watch(context.Background())
+		opts = append(opts, grpc.Creds(r.TransportCredentials()))
+		logrus.Infof("TLS enabled, client certificate required: %t", conf.TLS.ClientAuth)
+	}
+	if conf.Auth.Enabled {
+		authenticator, err := auth.NewAuthenticator(conf.Auth)
+		if err != nil {
+			logrus.Fatalf("failed to load auth tokens, detail: %s", err)
+		}
+		opts = append(opts,
+			grpc.UnaryInterceptor(authenticator.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(authenticator.StreamServerInterceptor()),
+		)
+		logrus.Infof("auth enabled, probes without valid bearer token are rejected")
+	}
+	serv := grpc.NewServer(opts...)
+	transmit.RegisterTransmitServer(serv, &transmit.CenterServer{IPSet: ips, Backend: fanout})
 	if err := serv.Serve(lis); err != nil {
 		logrus.Fatalf("failed to start grpc transmit server, detail: %s", err)
 	}
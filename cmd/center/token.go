@@ -0,0 +1,42 @@
+package main
+
+import (
+	"BlankZhu/wakizashi/pkg/auth"
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// token signs a token of the tenant with the HMAC secret in center's config, and prints it to stdout
+func token(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	cfgPathPtr := fs.String("c", constant.CenterDefaultConfigPath, "path to center's config yaml file")
+	tenantPtr := fs.String("tenant", "", "tenant attached to the records reported with the token")
+	ttlPtr := fs.Duration("ttl", 0, "time the token is valid for, like 720h; if 0, never expires")
+	fs.Parse(args)
+
+	if *tenantPtr == "" {
+		logrus.Fatalf("no tenant given, specify it by -tenant")
+	}
+	conf := config.CenterConfig{}
+	if err := conf.LoadConfigFromYAML(*cfgPathPtr); err != nil {
+		logrus.Fatalf("failed to load config from %s, detail: %s", *cfgPathPtr, err)
+	}
+	secret, err := conf.Auth.LoadHMACSecret()
+	if err != nil {
+		logrus.Fatalf("failed to load HMAC secret, detail: %s", err)
+	}
+	if secret == "" {
+		logrus.Fatalf("no HMAC secret in config, set auth.hmacSecret or auth.hmacSecretFile")
+	}
+
+	var expiry time.Time
+	if *ttlPtr > 0 {
+		expiry = time.Now().Add(*ttlPtr)
+	}
+	fmt.Println(auth.Sign([]byte(secret), *tenantPtr, expiry))
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"BlankZhu/wakizashi/pkg/auth"
+	"BlankZhu/wakizashi/pkg/cert"
 	"BlankZhu/wakizashi/pkg/config"
 	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/device"
+	"BlankZhu/wakizashi/pkg/discovery"
 	"BlankZhu/wakizashi/pkg/dump"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/filter"
+	"BlankZhu/wakizashi/pkg/identity"
+	"BlankZhu/wakizashi/pkg/probe"
 	"BlankZhu/wakizashi/pkg/report"
+	"BlankZhu/wakizashi/pkg/types"
+	"context"
 	"flag"
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
 )
 
 const title = `
@@ -31,17 +44,24 @@ var (
 	gitCommitID  string
 )
 
-func launchDumping(devs []net.Interface, conf *config.ProbeConfig, fileCh chan<- string) {
+func launchDumping(devs []net.Interface, counters []*types.CaptureCounter, conf *config.ProbeConfig, centers *discovery.Centers, fileCh chan<- string, recordCh chan<- *entity.RawTrafficRecord) {
 	var wg sync.WaitGroup
-	for _, dev := range devs {
+	for i, dev := range devs {
+		dev := dev
 		wg.Add(1)
 		dumper := dump.Dumper{
-			DumpDir:        conf.DumpDir,
-			FileCh:         fileCh,
-			Iface:          &dev,
-			RepAddr:        conf.CenterAddr,
-			RotateInterval: time.Duration(conf.CapInterval) * time.Second,
-			SnapLen:        uint32(256),
+			DumpDir:          conf.DumpDir,
+			FileCh:           fileCh,
+			RecordCh:         recordCh,
+			Iface:            &dev,
+			Centers:          centers,
+			RotateInterval:   time.Duration(conf.CapInterval) * time.Second,
+			SnapLen:          uint32(constant.ProbeSnapLen),
+			ServicePorts:     conf.ServicePorts,
+			EphemeralPortMin: conf.EphemeralPortMin,
+			BPFFilter:        conf.GetBPFFilter(dev.Name),
+			Counter:          counters[i],
+			Workers:          conf.CaptureWorkers,
 		}
 		dumper.Init()
 
@@ -53,32 +73,132 @@ func launchDumping(devs []net.Interface, conf *config.ProbeConfig, fileCh chan<-
 	wg.Wait()
 }
 
-func launchReporting(devs []net.Interface, conf *config.ProbeConfig, fileCh <-chan string) {
-	var wg sync.WaitGroup
+func launchReporting(ctx context.Context, devs []net.Interface, counters []*types.CaptureCounter, status *types.ReporterStatus, conf *config.ProbeConfig, centers *discovery.Centers, creds credentials.TransportCredentials, fileCh <-chan string, recordCh <-chan *entity.RawTrafficRecord) error {
 	reporter := report.Reporter{
-		AutoClear:   conf.AutoClear,
-		DumpDir:     conf.DumpDir,
-		FileCh:      fileCh,
-		Ifaces:      devs,
-		RepAddr:     conf.CenterAddr,
-		RepInterval: time.Duration(conf.CapInterval/2) * time.Second,
-		RepRetry:    conf.UploadRetry,
+		AutoClear:    conf.AutoClear,
+		DumpDir:      conf.DumpDir,
+		FileCh:       fileCh,
+		RecordCh:     recordCh,
+		MaxCacheSize: conf.MaxCache,
+		Ifaces:       devs,
+		Centers:      centers,
+		RepInterval:  time.Duration(conf.CapInterval) * time.Second / 2,
+		RepRetry:     conf.UploadRetry,
+		RepDeadline:  time.Duration(conf.RetryDeadline) * time.Second,
+		Status:       status,
+		Counters:     counters,
+		Creds:        creds,
+		Token:        loadToken(conf),
+		Info:         loadIdentity(conf),
+		RepBackoff: report.Backoff{
+			Min: time.Duration(conf.RetryBackoffMin) * time.Second,
+			Max: time.Duration(conf.RetryBackoffMax) * time.Second,
+		},
 	}
 	reporter.Init()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		reporter.Start()
-	}()
-	wg.Wait()
+	return reporter.Start(ctx)
+}
+
+func launchStatsProbe(port int, counters []*types.CaptureCounter, status *types.ReporterStatus) {
+	sp := probe.StatsProbe{Counters: counters, Reporter: status}
+	if err := sp.Start(uint16(port)); err != nil {
+		logrus.Errorf("stats probe on port %d stopped, detail: %s", port, err)
+	}
+}
+
+// watchCenters resolves the center addresses, and resolves them again periodically until ctx is done
+func watchCenters(ctx context.Context, conf *config.ProbeConfig) *discovery.Centers {
+	addrs := conf.GetCenterAddrs()
+	if len(addrs) == 0 {
+		logrus.Fatalf("no center address given, set centerAddr or centerAddrs")
+	}
+	centers := discovery.NewCenters(addrs)
+	centers.Resolve()
+	go centers.Watch(ctx, time.Duration(conf.CenterResolveInterval)*time.Second)
+	return centers
+}
+
+// loadCredentials return the TLS credentials to connect to center, reloaded once the files change until ctx is done;
+// if TLS is disabled, return nil
+func loadCredentials(ctx context.Context, conf *config.ProbeConfig) credentials.TransportCredentials {
+	if !conf.TLS.Enabled {
+		return nil
+	}
+	r, err := cert.NewReloader(conf.TLS, cert.Client)
+	if err != nil {
+		logrus.Fatalf("failed to load TLS certificates, detail: %s", err)
+	}
+	go r.Watch(ctx)
+	return r.TransportCredentials()
+}
+
+// loadToken return the credentials presenting bearer token to center, or nil if no token is configured
+func loadToken(conf *config.ProbeConfig) credentials.PerRPCCredentials {
+	if conf.Auth.Token == "" && conf.Auth.TokenFile == "" {
+		return nil
+	}
+	if _, err := conf.Auth.Load(); err != nil {
+		logrus.Fatalf("failed to load bearer token, detail: %s", err)
+	}
+	if !conf.TLS.Enabled {
+		logrus.Warnf("bearer token is sent to center in plaintext as TLS is disabled and allowInsecureToken is set")
+	}
+	return &auth.Credentials{Token: conf.Auth.TokenConfig, AllowInsecure: conf.Auth.AllowInsecureToken}
+}
+
+// loadIdentity return the identity of probe reported to center
+func loadIdentity(conf *config.ProbeConfig) *entity.ProbeInfo {
+	info, err := identity.Load(conf.Identity, conf.DumpDir)
+	if err != nil {
+		logrus.Fatalf("failed to load identity of probe, detail: %s", err)
+	}
+	logrus.Infof("probe identity: %+v", *info)
+	return info
+}
+
+// handleSignal cancels the probe's context on SIGINT & SIGTERM
+func handleSignal(ctx context.Context, cancel context.CancelFunc) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case sig := <-sigCh:
+		logrus.Warnf("received signal %s, stopping wakizashi probe", sig)
+		cancel()
+	case <-ctx.Done():
+	}
+}
+
+// getNetworkDevices return the network devices matching the regex in config
+func getNetworkDevices(conf *config.ProbeConfig) []net.Interface {
+	devs := make([]net.Interface, 0)
+	for _, regex := range conf.NetworkDevs {
+		tmp, err := device.GetNetworkDevices(regex)
+		if err != nil {
+			logrus.Fatalf("failed to get device on regex %s, detail: %s", regex, err)
+		}
+		for _, dev := range tmp {
+			if _, err := device.GetLinkType(dev.Name); err != nil {
+				logrus.Warnf("network device %s is skipped, detail: %s", dev.Name, err)
+				continue
+			}
+			devs = append(devs, dev)
+		}
+	}
+	return devs
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay(os.Args[2:])
+		return
+	}
+
 	cfgPathPtr := flag.String("c", constant.ProbeDefaultConfigPath, "path to probe's config yaml file")
 	verPtr := flag.Bool("v", false, "print version info")
 	flag.Parse()
 
-	fmt.Println(title)
+	fmt.Print(title)
 	fmt.Printf("Build time: %s\nBuild version: %s\nGit commit ID: %s\n", buildTime, buildVersion, gitCommitID)
 	if *verPtr {
 		return
@@ -96,28 +216,41 @@ func main() {
 	}
 
 	// get network devices
-	devs := make([]net.Interface, 0)
-	for _, regex := range conf.NetworkDevs {
-		tmp, err := device.GetNetworkDevices(regex)
-		if err != nil {
-			logrus.Fatalf("failed to get device on regex %s, detail: %s", regex, err)
-		}
-		devs = append(devs, tmp...)
-	}
+	devs := getNetworkDevices(&conf)
 	if len(devs) == 0 {
 		logrus.Fatalf("no network device dectected, check the network environment")
 	}
+	for _, dev := range devs {
+		if expr := conf.GetBPFFilter(dev.Name); expr != "" {
+			linkType, _ := device.GetLinkType(dev.Name)
+			if _, err := filter.Compile(expr, linkType, constant.ProbeSnapLen); err != nil {
+				logrus.Fatalf("invalid BPF filter for device %s, detail: %s", dev.Name, err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignal(ctx, cancel)
+	creds := loadCredentials(ctx, &conf)
+	centers := watchCenters(ctx, &conf)
 
 	fileCh := make(chan string, constant.DefaultChanCap)
-	var wg sync.WaitGroup
-	go func() {
-		launchDumping(devs, &conf, fileCh)
-		defer wg.Done()
-	}()
-	go func() {
-		launchReporting(devs, &conf, fileCh)
-		defer wg.Done()
-	}()
-	wg.Wait()
-	logrus.Warn("wakizashi probe exit after dumper & repoter returned")
+	var recordCh chan *entity.RawTrafficRecord
+	if conf.CaptureMode == constant.CaptureModeMemory {
+		recordCh = make(chan *entity.RawTrafficRecord, constant.DefaultChanCap)
+	}
+	counters := make([]*types.CaptureCounter, 0, len(devs))
+	for _, dev := range devs {
+		counters = append(counters, types.NewCaptureCounter(dev.Name))
+	}
+	status := types.NewReporterStatus()
+	if conf.StatsPort > 0 {
+		go launchStatsProbe(conf.StatsPort, counters, status)
+	}
+	go launchDumping(devs, counters, &conf, centers, fileCh, recordCh)
+	if err := launchReporting(ctx, devs, counters, status, &conf, centers, creds, fileCh, recordCh); err != nil {
+		logrus.Fatalf("wakizashi probe exit as reporter gave up, detail: %s", err)
+	}
+	logrus.Warn("wakizashi probe exit after reporter returned")
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/discovery"
+	"BlankZhu/wakizashi/pkg/dump"
+	"BlankZhu/wakizashi/pkg/report"
+	"BlankZhu/wakizashi/pkg/util"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// replay feeds a pcap or pcapng file through the same decoding, filtering and aggregation as dumping,
+// then reports the traffic records to center, or prints them to stdout
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	cfgPathPtr := fs.String("c", constant.ProbeDefaultConfigPath, "path to probe's config yaml file")
+	filePtr := fs.String("f", "", "path to the pcap or pcapng file to replay")
+	probeIPPtr := fs.String("probe-ip", "", "comma separated IP of the probe where the file is captured, if empty, use IP of the configured network devices")
+	printPtr := fs.Bool("print", false, "print the traffic records in JSON lines instead of reporting to center")
+	fs.Parse(args)
+
+	if *filePtr == "" {
+		logrus.Fatalf("no file to replay, specify it by -f")
+	}
+
+	// load config, which is optional if records are only printed
+	conf := config.NewProbeConfig()
+	if err := conf.LoadConfigFromYAML(*cfgPathPtr); err != nil {
+		if !*printPtr {
+			logrus.Fatalf("failed to load config from %s, detail: %s", *cfgPathPtr, err)
+		}
+		logrus.Warnf("failed to load config from %s, using default config, detail: %s", *cfgPathPtr, err)
+	} else {
+		logrus.SetLevel(logrus.Level(conf.LogLev))
+	}
+
+	var probeIPs map[string]struct{}
+	if *probeIPPtr != "" {
+		probeIPs = make(map[string]struct{})
+		for _, ip := range strings.Split(*probeIPPtr, ",") {
+			normalized := util.NormalizeIP(strings.TrimSpace(ip))
+			if normalized == "" {
+				logrus.Fatalf("invalid probe IP %s", ip)
+			}
+			probeIPs[normalized] = struct{}{}
+		}
+	} else {
+		probeIPs = util.GetIPSetFromNetworkInterfaces(getNetworkDevices(conf))
+	}
+	if len(probeIPs) == 0 {
+		logrus.Fatalf("no probe IP to replay the traffic for, specify it by -probe-ip")
+	}
+
+	centers := discovery.NewCenters(conf.GetCenterAddrs())
+	centers.Resolve()
+	centerIPs, _ := centers.IPs()
+	dec := &dump.Decoder{
+		ProbeIPs:         probeIPs,
+		CenterIPs:        centerIPs,
+		ServicePorts:     conf.ServicePorts,
+		EphemeralPortMin: conf.EphemeralPortMin,
+	}
+	agg := report.Aggregator{
+		Interval: time.Duration(conf.CapInterval) * time.Second,
+		ProbeIPs: probeIPs,
+	}
+	agg.Init()
+	if err := dump.Replay(*filePtr, dec, agg.Add); err != nil {
+		logrus.Fatalf("failed to replay %s, detail: %s", *filePtr, err)
+	}
+	records := agg.Records()
+
+	if *printPtr {
+		for _, record := range records {
+			str, err := record.ToJSONString()
+			if err != nil {
+				logrus.Warnf("failed to parse record to JSON string: %v", record)
+				continue
+			}
+			fmt.Println(str)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignal(ctx, cancel)
+
+	reporter := report.Reporter{
+		DumpDir: conf.DumpDir,
+		Centers: centers,
+		Creds:   loadCredentials(ctx, conf),
+		Token:   loadToken(conf),
+		Info:    loadIdentity(conf),
+	}
+	reporter.Init()
+	if err := reporter.Upload(ctx, records); err != nil {
+		logrus.Fatalf("failed to report replayed records to center, detail: %s", err)
+	}
+	logrus.Infof("%d traffic records replayed from %s reported to center", len(records), *filePtr)
+}
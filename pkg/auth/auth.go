@@ -0,0 +1,141 @@
+// Package auth describes the bearer tokens probes present to center in grpc metadata, and the tenants center
+// resolves them to. A token is either one of the static tokens in center's config, or a token signed by the
+// HMAC secret in center's config, carrying its tenant.
+package auth
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metadataKey  = "authorization"
+	bearerPrefix = "Bearer "
+)
+
+type tenantKey struct{}
+
+// TenantFromContext return the tenant of the authenticated probe, or empty if auth is disabled
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Authenticator validates the tokens presented by probes, and resolves their tenants
+type Authenticator struct {
+	tokens map[[sha256.Size]byte]string // tenants by the hashes of static tokens
+	secret []byte                       // secret verifying signed tokens
+}
+
+// NewAuthenticator loads the static tokens and secret in config
+func NewAuthenticator(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		tokens: make(map[[sha256.Size]byte]string, len(cfg.Tokens)),
+	}
+	for _, t := range cfg.Tokens {
+		token, err := t.Load()
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, fmt.Errorf("empty token for tenant %s", t.Tenant)
+		}
+		a.tokens[sha256.Sum256([]byte(token))] = t.Tenant
+	}
+	secret, err := cfg.LoadHMACSecret()
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		a.secret = []byte(secret)
+	}
+	return a, nil
+}
+
+// Authenticate validates the token, and return the tenant it resolves to
+func (a *Authenticator) Authenticate(token string) (string, error) {
+	// static tokens are looked up by hash, so the comparison does not leak them by timing
+	if tenant, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return tenant, nil
+	}
+	if a.secret != nil && strings.Count(token, ".") == 2 {
+		return Verify(a.secret, token, time.Now())
+	}
+	return "", fmt.Errorf("unknown token")
+}
+
+// UnaryServerInterceptor rejects the unauthenticated unary calls, and attaches the tenant to the context
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects the unauthenticated streams, and attaches the tenant to the context
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(metadataKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	tenant, err := a.Authenticate(strings.TrimPrefix(values[0], bearerPrefix))
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid bearer token: %s", err)
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant), nil
+}
+
+// tenantStream overrides the context of stream with the one carrying tenant
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+// Credentials are grpc per-RPC credentials presenting the bearer token, the token file is read on every call,
+// so rotated tokens take effect on next connection
+type Credentials struct {
+	Token         config.TokenConfig
+	AllowInsecure bool // allow the token to be sent in plaintext, see config.ProbeAuthConfig
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials
+func (c *Credentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.Token.Load()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{metadataKey: bearerPrefix + token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials, tokens are sent over TLS only,
+// unless AllowInsecure is set
+func (c *Credentials) RequireTransportSecurity() bool {
+	return !c.AllowInsecure
+}
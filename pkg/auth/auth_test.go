@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a, err := NewAuthenticator(config.AuthConfig{
+		Enabled: true,
+		Tokens: []config.TenantToken{
+			{Tenant: "team-a", TokenConfig: config.TokenConfig{Token: "static-a"}},
+			{Tenant: "team-b", TokenConfig: config.TokenConfig{Token: "static-b"}},
+		},
+		HMACSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator, detail: %s", err)
+	}
+	tests := []struct {
+		name     string
+		metadata []string
+		want     string
+		wantCode codes.Code
+	}{
+		{name: "static token", metadata: []string{"authorization", "Bearer static-b"}, want: "team-b"},
+		{name: "signed token", metadata: []string{"authorization", "Bearer " + Sign([]byte("secret"), "team-c", time.Now().Add(time.Hour))}, want: "team-c"},
+		{name: "expired signed token", metadata: []string{"authorization", "Bearer " + Sign([]byte("secret"), "team-c", time.Now().Add(-time.Hour))}, wantCode: codes.Unauthenticated},
+		{name: "unknown token", metadata: []string{"authorization", "Bearer static-c"}, wantCode: codes.Unauthenticated},
+		{name: "not bearer", metadata: []string{"authorization", "Basic static-a"}, wantCode: codes.Unauthenticated},
+		{name: "no token", wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+			var got string
+			_, err := a.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				got = TenantFromContext(ctx)
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s", code, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("got tenant %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorEmptyToken(t *testing.T) {
+	_, err := NewAuthenticator(config.AuthConfig{Enabled: true, Tokens: []config.TenantToken{{Tenant: "team-a"}}})
+	if err == nil {
+		t.Error("got no error for empty token")
+	}
+}
+
+func TestCredentialsTransportSecurity(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowInsecure bool
+		wantErr       bool
+	}{
+		{name: "token refused in plaintext", wantErr: true},
+		{name: "token allowed in plaintext", allowInsecure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := &Credentials{Token: config.TokenConfig{Token: "static-a"}, AllowInsecure: tt.allowInsecure}
+			if creds.RequireTransportSecurity() == tt.allowInsecure {
+				t.Errorf("got transport security required %v, want %v", creds.RequireTransportSecurity(), !tt.allowInsecure)
+			}
+			md, err := creds.GetRequestMetadata(context.Background())
+			if err != nil || md["authorization"] != "Bearer static-a" {
+				t.Errorf("got metadata %v and error %v", md, err)
+			}
+
+			// grpc refuses to dial in plaintext with credentials requiring transport security
+			conn, err := grpc.Dial("127.0.0.1:1", grpc.WithInsecure(), grpc.WithPerRPCCredentials(creds))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v dialing in plaintext, want error %v", err, tt.wantErr)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		})
+	}
+}
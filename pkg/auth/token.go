@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sign creates a token of the tenant signed by secret, valid until expiry; if expiry is zero, it never expires.
+// The token looks like [base64 tenant].[expiry in unix time].[base64 HMAC-SHA256 of the former two parts]
+func Sign(secret []byte, tenant string, expiry time.Time) string {
+	var exp int64
+	if !expiry.IsZero() {
+		exp = expiry.Unix()
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(tenant)) + "." + strconv.FormatInt(exp, 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac(secret, payload))
+}
+
+// Verify checks the signed token against secret, and return the tenant it carries
+func Verify(secret []byte, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed signed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed signature of token")
+	}
+	if !hmac.Equal(sig, mac(secret, parts[0]+"."+parts[1])) {
+		return "", fmt.Errorf("invalid signature of token")
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("malformed expiry of token")
+	}
+	if exp != 0 && now.Unix() >= exp {
+		return "", fmt.Errorf("token expired at %s", time.Unix(exp, 0))
+	}
+	tenant, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed tenant of token")
+	}
+	return string(tenant), nil
+}
+
+func mac(secret []byte, payload string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1622534400, 0)
+	valid := Sign(secret, "team-a", now.Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", token: valid, want: "team-a"},
+		{name: "never expires", token: Sign(secret, "team-b", time.Time{}), want: "team-b"},
+		{name: "tenant with dots", token: Sign(secret, "org.team/a b", now.Add(time.Second)), want: "org.team/a b"},
+		{name: "empty tenant", token: Sign(secret, "", now.Add(time.Second)), want: ""},
+		{name: "expired", token: Sign(secret, "team-a", now.Add(-time.Second)), wantErr: true},
+		{name: "expires now", token: Sign(secret, "team-a", now), wantErr: true},
+		{name: "other secret", token: Sign([]byte("other"), "team-a", now.Add(time.Hour)), wantErr: true},
+		{name: "tampered tenant", token: base64.RawURLEncoding.EncodeToString([]byte("team-b")) + "." + parts[1] + "." + parts[2], wantErr: true},
+		{name: "tampered expiry", token: parts[0] + ".0." + parts[2], wantErr: true},
+		{name: "tampered signature", token: parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), wantErr: true},
+		{name: "malformed signature", token: parts[0] + "." + parts[1] + ".!!", wantErr: true},
+		{name: "missing part", token: parts[0] + "." + parts[1], wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(secret, tt.token, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got tenant %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
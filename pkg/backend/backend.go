@@ -2,16 +2,15 @@ package backend
 
 import (
 	"BlankZhu/wakizashi/pkg/config"
-	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/entity"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
-
-	"github.com/sirupsen/logrus"
 )
 
-var once sync.Once
-var backend DataBackend
-
+// DataBackend is the data storage backend where the traffic records are written to
 type DataBackend interface {
 	Connect() error
 	Close() error
@@ -19,21 +18,97 @@ type DataBackend interface {
 	WriteBatch([]*entity.TrafficRecord) error
 }
 
-func Init(cfg config.BackendConfig) {
-	once.Do(func() {
-		switch cfg.Type {
-		case constant.BackendInfluxDB:
-			backend = createMongoClient(cfg)
-		case constant.BackendRedis:
-			backend = createInfluxClient(cfg)
-		case constant.BackendMongoDB:
-			backend = createRedisClient(cfg)
-		default:
-			logrus.Fatalf("invalid backend type %s", cfg.Type)
-		}
-	})
+// Factory creates a data backend from the config
+type Factory func(cfg config.BackendConfig) (DataBackend, error)
+
+var factoriesMtx sync.RWMutex
+var factories = make(map[string]Factory)
+
+// Register makes a data backend available by the name, used as the type in backend config.
+// It panics if Register is called twice with the same name or the factory is nil
+func Register(name string, factory Factory) {
+	factoriesMtx.Lock()
+	defer factoriesMtx.Unlock()
+	if factory == nil {
+		panic("backend: register a nil factory for " + name)
+	}
+	if _, dup := factories[name]; dup {
+		panic("backend: register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Backends return the sorted names of registered data backends
+func Backends() []string {
+	factoriesMtx.RLock()
+	defer factoriesMtx.RUnlock()
+	ret := make([]string, 0, len(factories))
+	for name := range factories {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
 }
 
-func Get() *DataBackend {
-	return &backend
+// New creates a data backend by the type in config, the backend is not connected yet
+func New(cfg config.BackendConfig) (DataBackend, error) {
+	factoriesMtx.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("invalid backend type %s, registered: %v", cfg.Type, Backends())
+	}
+	return factory(cfg)
+}
+
+// labelTagPrefix prefix of the tag names of workload labels, like labels.app
+const labelTagPrefix = "labels."
+
+// recordTags return the attributes of record by their tag names, used by the backends storing records as tagged series.
+// The labels of workload are named by labelTagPrefix and the label name
+func recordTags(record *entity.TrafficRecord) map[string]string {
+	ret := map[string]string{
+		"probeIP":   record.ProbeIP,
+		"srcIP":     record.SrcIP,
+		"dstIP":     record.DstIP,
+		"protocol":  record.Protocol,
+		"srcPort":   strconv.Itoa(int(record.SrcPort)),
+		"dstPort":   strconv.Itoa(int(record.DstPort)),
+		"direction": record.Direction,
+		"tenant":    record.Tenant,
+		"probeID":   record.ProbeID,
+		"hostname":  record.Hostname,
+		"node":      record.Node,
+		"pod":       record.Pod,
+		"namespace": record.Namespace,
+	}
+	for name, value := range record.Labels {
+		ret[labelTagPrefix+name] = value
+	}
+	return ret
+}
+
+// selectTags validates the tag names wanted against recordTags, and returns them sorted; if none wanted, return all
+// but the labels of workload, which are selected by their names like labels.app
+func selectTags(wanted []string) ([]string, error) {
+	available := make([]string, 0)
+	for name := range recordTags(&entity.TrafficRecord{}) {
+		available = append(available, name)
+	}
+	sort.Strings(available)
+	if len(wanted) == 0 {
+		return available, nil
+	}
+
+	tags := append([]string{}, wanted...)
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, labelTagPrefix) && len(tag) > len(labelTagPrefix) {
+			continue
+		}
+		if i := sort.SearchStrings(available, tag); i == len(available) || available[i] != tag {
+			return nil, fmt.Errorf("invalid tag %s, use %s or %s[label name]", tag, strings.Join(available, ", "), labelTagPrefix)
+		}
+	}
+	return tags, nil
 }
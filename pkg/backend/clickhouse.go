@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clickHouseRow is a row of the table, inserted in JSONEachRow format
+type clickHouseRow struct {
+	Time      int64             `json:"time"`
+	ProbeIP   string            `json:"probe_ip"`
+	SrcIP     string            `json:"src_ip"`
+	DstIP     string            `json:"dst_ip"`
+	Protocol  string            `json:"protocol"`
+	SrcPort   uint16            `json:"src_port"`
+	DstPort   uint16            `json:"dst_port"`
+	Direction string            `json:"direction"`
+	Size      uint64            `json:"size"`
+	Packets   uint64            `json:"packets"`
+	Tenant    string            `json:"tenant"`
+	ProbeID   string            `json:"probe_id"`
+	Hostname  string            `json:"hostname"`
+	Node      string            `json:"node"`
+	Pod       string            `json:"pod"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// clickHouseClient writes the records into a MergeTree table of ClickHouse partitioned by day, over HTTP interface
+type clickHouseClient struct {
+	client *http.Client
+	cfg    config.BackendConfig
+	url    string
+}
+
+func init() {
+	Register(constant.BackendClickHouse, createClickHouseClient)
+}
+
+func createClickHouseClient(cfg config.BackendConfig) (DataBackend, error) {
+	if _, err := url.Parse(cfg.ChCfg.URL); err != nil {
+		return nil, fmt.Errorf("invalid clickhouse url %s, detail: %s", cfg.ChCfg.URL, err)
+	}
+	ret := &clickHouseClient{
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		cfg:    cfg,
+		url:    strings.TrimRight(cfg.ChCfg.URL, "/") + "/",
+	}
+	return ret, nil
+}
+
+// Connect connects to clickhouse, and creates the database and table if not exist
+func (cc *clickHouseClient) Connect() error {
+	ttl := ""
+	if cc.cfg.ChCfg.TTLDays != 0 {
+		ttl = fmt.Sprintf("\nTTL time + INTERVAL %d DAY", cc.cfg.ChCfg.TTLDays)
+	}
+	stmts := []string{
+		fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteClickHouseIdentifier(cc.cfg.Database)),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	time DateTime,
+	probe_ip String,
+	src_ip String,
+	dst_ip String,
+	protocol LowCardinality(String),
+	src_port UInt16,
+	dst_port UInt16,
+	direction LowCardinality(String),
+	size UInt64,
+	packets UInt64,
+	tenant LowCardinality(String),
+	probe_id String,
+	hostname String,
+	node LowCardinality(String),
+	pod String,
+	namespace LowCardinality(String),
+	labels Map(String, String)
+) ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(time)
+ORDER BY (probe_ip, time)%s`, cc.qualifiedTable(), ttl),
+	}
+	for _, stmt := range stmts {
+		if err := cc.exec(stmt, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cc *clickHouseClient) Close() error {
+	cc.client.CloseIdleConnections()
+	return nil
+}
+
+func (cc *clickHouseClient) Write(record *entity.TrafficRecord) error {
+	return cc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch inserts the records in one request, as clickhouse prefers large and infrequent inserts
+func (cc *clickHouseClient) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	enc := json.NewEncoder(body)
+	for _, r := range records {
+		row := clickHouseRow{
+			Time:      r.Timestamp,
+			ProbeIP:   r.ProbeIP,
+			SrcIP:     r.SrcIP,
+			DstIP:     r.DstIP,
+			Protocol:  r.Protocol,
+			SrcPort:   r.SrcPort,
+			DstPort:   r.DstPort,
+			Direction: r.Direction,
+			Size:      r.Size,
+			Packets:   r.Packets,
+			Tenant:    r.Tenant,
+			ProbeID:   r.ProbeID,
+			Hostname:  r.Hostname,
+			Node:      r.Node,
+			Pod:       r.Pod,
+			Namespace: r.Namespace,
+			Labels:    r.Labels,
+		}
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return cc.exec(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", cc.qualifiedTable()), body)
+}
+
+// exec executes the query, with data in body if given
+func (cc *clickHouseClient) exec(query string, body io.Reader) error {
+	u := cc.url + "?query=" + url.QueryEscape(query)
+	if body == nil {
+		u = cc.url
+		body = strings.NewReader(query)
+	}
+	req, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return err
+	}
+	if cc.cfg.ChCfg.User != "" {
+		req.Header.Set("X-ClickHouse-User", cc.cfg.ChCfg.User)
+		req.Header.Set("X-ClickHouse-Key", cc.cfg.ChCfg.Password)
+	}
+
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("clickhouse responded %s, detail: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (cc *clickHouseClient) qualifiedTable() string {
+	return quoteClickHouseIdentifier(cc.cfg.Database) + "." + quoteClickHouseIdentifier(cc.cfg.Table)
+}
+
+func quoteClickHouseIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
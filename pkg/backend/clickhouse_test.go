@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClickHouseWriteBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*entity.TrafficRecord
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "rows",
+			records: []*entity.TrafficRecord{
+				{Timestamp: 1622534400, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.3", Protocol: "TCP",
+					DstPort: 443, Direction: "egress", Size: 1500, Packets: 3},
+				{Timestamp: 1622534401, ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::3", Protocol: "UDP",
+					SrcPort: 53, Direction: "ingress", Size: 80, Packets: 1, Tenant: "team-a", ProbeID: "id-1",
+					Hostname: "host", Node: "node-1", Pod: "pod-1", Namespace: "ns", Labels: map[string]string{"app": "web"}},
+			},
+			status: http.StatusOK,
+			want: `{"time":1622534400,"probe_ip":"10.0.0.1","src_ip":"10.0.0.2","dst_ip":"10.0.0.3","protocol":"TCP","src_port":0,"dst_port":443,"direction":"egress","size":1500,"packets":3,"tenant":"","probe_id":"","hostname":"","node":"","pod":"","namespace":"","labels":null}
+{"time":1622534401,"probe_ip":"fd00::1","src_ip":"fd00::2","dst_ip":"fd00::3","protocol":"UDP","src_port":53,"dst_port":0,"direction":"ingress","size":80,"packets":1,"tenant":"team-a","probe_id":"id-1","hostname":"host","node":"node-1","pod":"pod-1","namespace":"ns","labels":{"app":"web"}}
+`,
+		},
+		{
+			name:    "rejected",
+			records: []*entity.TrafficRecord{{Timestamp: 1622534400, Protocol: "TCP", Direction: "egress"}},
+			status:  http.StatusBadRequest,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query, body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-ClickHouse-User") != "writer" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				data, _ := ioutil.ReadAll(r.Body)
+				query, body = r.URL.Query().Get("query"), string(data)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			cli, err := createClickHouseClient(config.BackendConfig{Database: "wakizashi", Table: "traffic", Timeout: 5,
+				ChCfg: config.ClickHouseConfig{URL: srv.URL, User: "writer", Password: "secret"}})
+			if err != nil {
+				t.Fatalf("failed to create client, detail: %s", err)
+			}
+			err = cli.WriteBatch(tt.records)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if wantQuery := "INSERT INTO `wakizashi`.`traffic` FORMAT JSONEachRow"; query != wantQuery {
+				t.Errorf("got query %s, want %s", query, wantQuery)
+			}
+			if !tt.wantErr && body != tt.want {
+				t.Errorf("got rows\n%s\nwant\n%s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteClickHouseIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"traffic", "`traffic`"},
+		{"we`ird", "`we\\`ird`"},
+		{`back\slash`, "`back\\\\slash`"},
+	}
+	for _, tt := range tests {
+		if got := quoteClickHouseIdentifier(tt.name); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/recovery"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is a data backend with its own recovery as the queue of records. Records are spooled to the recovery first,
+// and written to the backend from it in background, so a slow or down backend never holds up the others.
+// After consecutive failures, the backend is regarded unhealthy, and records are kept in recovery until the cooldown
+// passes. A backend failed to connect is degraded, its records are kept until it connects in background
+type Sink struct {
+	Name      string
+	backend   DataBackend
+	recovery  *recovery.Recovery
+	failures  int32         // consecutive failures, accessed atomically
+	openUntil int64         // unix nano until which the backend is regarded unhealthy, accessed atomically
+	connected int32         // 1 once the backend is connected, accessed atomically
+	spooled   chan struct{} // signal to write the spooled records to backend
+	draining  sync.WaitGroup
+	done      chan struct{}
+}
+
+// NewSink creates the sink of the data backend, with recovery files in recovDir
+func NewSink(name string, backend DataBackend, recovDir string) (*Sink, error) {
+	if err := os.MkdirAll(recovDir, 0755); err != nil {
+		return nil, err
+	}
+	ret := &Sink{
+		Name:     name,
+		backend:  backend,
+		recovery: recovery.New(),
+		spooled:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	// records are reposted in batches by RepostBatch, never one by one
+	err := ret.recovery.Init(
+		path.Join(recovDir, constant.RecoveryDefaultFileName),
+		path.Join(recovDir, constant.RecoveryDefaultPosName),
+		constant.RecoveryDefaultPosLimit,
+		constant.RecoveryDefaultCacheSize,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Connect connects the backend, if failed, the sink is degraded and keeps connecting in background until Close.
+// The spooled records are written to backend in background once connected
+func (s *Sink) Connect() {
+	s.draining.Add(1)
+	go s.drain()
+	if err := s.backend.Connect(); err != nil {
+		logrus.Errorf("failed to connect to backend %s, records are spooled to its recovery until connected, detail: %s", s.Name, err)
+		go s.reconnect()
+		return
+	}
+	atomic.StoreInt32(&s.connected, 1)
+	s.notify()
+}
+
+func (s *Sink) reconnect() {
+	ticker := time.NewTicker(constant.BackendReconnectInterval * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		if err := s.backend.Connect(); err != nil {
+			logrus.Warnf("failed to connect to backend %s, will retry in %d seconds, detail: %s", s.Name, constant.BackendReconnectInterval, err)
+			continue
+		}
+		atomic.StoreInt32(&s.connected, 1)
+		logrus.Infof("backend %s connected", s.Name)
+		s.notify()
+		return
+	}
+}
+
+// Degraded tells if the backend is not connected yet
+func (s *Sink) Degraded() bool {
+	return atomic.LoadInt32(&s.connected) == 0
+}
+
+// Healthy tells if the backend is connected and regarded healthy, or the cooldown has passed so it can be tried again
+func (s *Sink) Healthy() bool {
+	return !s.Degraded() && time.Now().UnixNano() >= atomic.LoadInt64(&s.openUntil)
+}
+
+// Close stops connecting and writing the backend, and closes it if connected.
+// The records not written yet are kept in recovery
+func (s *Sink) Close() error {
+	close(s.done)
+	s.draining.Wait()
+	if s.Degraded() {
+		return nil
+	}
+	return s.backend.Close()
+}
+
+// WriteBatch spools the records to recovery, and signals to write them to backend in background,
+// error is returned if the records are not spooled
+func (s *Sink) WriteBatch(records []*entity.TrafficRecord) error {
+	if err := s.recovery.Spool(records); err != nil {
+		return fmt.Errorf("failed to spool records to recovery of backend %s, detail: %s", s.Name, err)
+	}
+	s.notify()
+	return nil
+}
+
+func (s *Sink) notify() {
+	select {
+	case s.spooled <- struct{}{}:
+	default:
+	}
+}
+
+// drain writes the spooled records to backend once signaled, until Close
+func (s *Sink) drain() {
+	defer s.draining.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.spooled:
+		}
+		s.RepostRecord()
+	}
+}
+
+// RepostRecord reposts the spooled records in batches if the backend is healthy, the records failed to write are
+// kept in recovery and reposted next time
+func (s *Sink) RepostRecord() {
+	if s.Healthy() {
+		s.recovery.RepostBatch(constant.BackendRepostBatchSize, s.write)
+	}
+}
+
+// write writes the records to backend, and updates the health of backend
+func (s *Sink) write(records []*entity.TrafficRecord) error {
+	err := s.backend.WriteBatch(records)
+	if err == nil {
+		if atomic.SwapInt32(&s.failures, 0) >= constant.BackendBreakerThreshold {
+			logrus.Infof("backend %s recovered", s.Name)
+		}
+		return nil
+	}
+	if atomic.AddInt32(&s.failures, 1) >= constant.BackendBreakerThreshold {
+		logrus.Warnf("backend %s is unhealthy, records are spooled to recovery in next %d seconds", s.Name, constant.BackendBreakerCooldown)
+		atomic.StoreInt64(&s.openUntil, time.Now().Add(constant.BackendBreakerCooldown*time.Second).UnixNano())
+	}
+	return err
+}
+
+// Fanout writes every record to all sinks, it implements DataBackend
+type Fanout struct {
+	Sinks []*Sink
+}
+
+// Connect connects all the data backends, a backend failed to connect is degraded rather than failing the others
+func (f *Fanout) Connect() error {
+	for _, s := range f.Sinks {
+		s.Connect()
+	}
+	return nil
+}
+
+// Close closes all the data backends
+func (f *Fanout) Close() error {
+	var errs []string
+	for _, s := range f.Sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Name, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to close backends, detail: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Write writes the record to all sinks, see WriteBatch
+func (f *Fanout) Write(record *entity.TrafficRecord) error {
+	return f.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch spools the records to all sinks concurrently, returns after every sink has spooled them,
+// so the records are safely on disk while the sinks write them to their backends in background.
+// Error is returned if any sink fails to spool them
+func (f *Fanout) WriteBatch(records []*entity.TrafficRecord) error {
+	errs := make([]error, len(f.Sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.Sinks {
+		wg.Add(1)
+		go func(i int, s *Sink) {
+			defer wg.Done()
+			errs[i] = s.WriteBatch(records)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) != 0 {
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend records the written records, each WriteBatch blocks until release is closed if it is not nil
+type fakeBackend struct {
+	release chan struct{}
+	mu      sync.Mutex
+	records []*entity.TrafficRecord
+}
+
+func (fb *fakeBackend) Connect() error { return nil }
+func (fb *fakeBackend) Close() error   { return nil }
+
+func (fb *fakeBackend) Write(record *entity.TrafficRecord) error {
+	return fb.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+func (fb *fakeBackend) WriteBatch(records []*entity.TrafficRecord) error {
+	if fb.release != nil {
+		<-fb.release
+	}
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	fb.records = append(fb.records, records...)
+	return nil
+}
+
+func (fb *fakeBackend) written() int {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return len(fb.records)
+}
+
+// waitWritten waits until the backend has written n records
+func waitWritten(t *testing.T, name string, fb *fakeBackend, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for fb.written() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("backend %s wrote %d records, want %d", name, fb.written(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFanoutWriteBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fanout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fast := &fakeBackend{}
+	slow := &fakeBackend{release: make(chan struct{})}
+	f := &Fanout{}
+	for name, fb := range map[string]*fakeBackend{"fast": fast, "slow": slow} {
+		sink, err := NewSink(name, fb, filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to create sink %s, detail: %s", name, err)
+		}
+		f.Sinks = append(f.Sinks, sink)
+	}
+	if err := f.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := []*entity.TrafficRecord{
+		{Timestamp: 1, SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 100},
+		{Timestamp: 2, SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 200},
+	}
+	acked := make(chan error, 1)
+	go func() { acked <- f.WriteBatch(records) }()
+	select {
+	case err := <-acked:
+		if err != nil {
+			t.Fatalf("failed to write batch, detail: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("write batch is held up by the slow backend")
+	}
+	waitWritten(t, "fast", fast, len(records))
+
+	close(slow.release)
+	waitWritten(t, "slow", slow, len(records))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
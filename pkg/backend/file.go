@@ -0,0 +1,339 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fileTimeLayout layout of the time in names of rotated files, sorted as the time they are rotated
+const fileTimeLayout = "20060102-150405.000000"
+
+// fileCSVHeader header of the csv files
+var fileCSVHeader = []string{"timestamp", "probeIP", "srcIP", "dstIP", "protocol", "srcPort", "dstPort", "direction",
+	"size", "packets", "tenant", "probeID", "hostname", "node", "pod", "namespace", "labels"}
+
+// fileClient appends the records to [dir]/[table].jsonl (or .csv). Once the file grows over max size or lives over
+// the rotate interval, it is renamed to [dir]/[table]-[time].jsonl and a new file is started, the rotated file is
+// gzipped if required and pruned in background.
+type fileClient struct {
+	mu       sync.Mutex
+	compact  sync.Mutex     // serializes compressing and pruning of the rotated files
+	compacts sync.WaitGroup // compressing and pruning in progress
+	cfg      config.BackendConfig
+	csv      bool
+	ext      string
+	maxSize  int64
+	interval time.Duration
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func init() {
+	Register(constant.BackendFile, createFileClient)
+}
+
+func createFileClient(cfg config.BackendConfig) (DataBackend, error) {
+	fcfg := cfg.FileCfg
+	if fcfg.Dir == "" {
+		return nil, fmt.Errorf("no directory given for file backend")
+	}
+	ret := &fileClient{
+		cfg:      cfg,
+		maxSize:  int64(fcfg.MaxSize) << 20,
+		interval: time.Duration(fcfg.RotateInterval) * time.Second,
+	}
+	if fcfg.MaxSize == 0 {
+		ret.maxSize = constant.FileDefaultMaxSize << 20
+	}
+	switch strings.ToLower(fcfg.Format) {
+	case "", "jsonl", "json":
+		ret.ext = ".jsonl"
+	case "csv":
+		ret.csv = true
+		ret.ext = ".csv"
+	default:
+		return nil, fmt.Errorf("invalid file format %s, should be jsonl or csv", fcfg.Format)
+	}
+	return ret, nil
+}
+
+// Connect creates the directory if not exists, and opens the current file for appending
+func (fc *fileClient) Connect() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if err := os.MkdirAll(fc.cfg.FileCfg.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s, detail: %s", fc.cfg.FileCfg.Dir, err)
+	}
+	if fc.file != nil {
+		return nil
+	}
+	return fc.open()
+}
+
+// Close closes the current file, and waits for the rotated files to be compressed and pruned
+func (fc *fileClient) Close() error {
+	defer fc.compacts.Wait()
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.file == nil {
+		return nil
+	}
+	err := fc.file.Close()
+	fc.file = nil
+	return err
+}
+
+func (fc *fileClient) Write(record *entity.TrafficRecord) error {
+	return fc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch appends the records to the current file, rotating it first if it is due
+func (fc *fileClient) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := fc.encode(records)
+	if err != nil {
+		return err
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.file == nil {
+		if err := fc.open(); err != nil {
+			return err
+		}
+	}
+	if fc.rotationDue(int64(len(data))) {
+		if err := fc.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := fc.file.Write(data)
+	fc.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write records to %s, detail: %s", fc.file.Name(), err)
+	}
+	return nil
+}
+
+func (fc *fileClient) encode(records []*entity.TrafficRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	if !fc.csv {
+		for _, r := range records {
+			s, err := r.ToJSONString()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode record, detail: %s", err)
+			}
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	}
+
+	w := csv.NewWriter(&buf)
+	for _, r := range records {
+		labels := ""
+		if len(r.Labels) != 0 {
+			b, err := json.Marshal(r.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode labels, detail: %s", err)
+			}
+			labels = string(b)
+		}
+		w.Write([]string{
+			strconv.FormatInt(r.Timestamp, 10),
+			r.ProbeIP,
+			r.SrcIP,
+			r.DstIP,
+			r.Protocol,
+			strconv.FormatUint(uint64(r.SrcPort), 10),
+			strconv.FormatUint(uint64(r.DstPort), 10),
+			r.Direction,
+			strconv.FormatUint(r.Size, 10),
+			strconv.FormatUint(r.Packets, 10),
+			r.Tenant,
+			r.ProbeID,
+			r.Hostname,
+			r.Node,
+			r.Pod,
+			r.Namespace,
+			labels,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode record, detail: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// currentPath path of the file being appended
+func (fc *fileClient) currentPath() string {
+	return filepath.Join(fc.cfg.FileCfg.Dir, fc.cfg.Table+fc.ext)
+}
+
+// open opens the current file, and writes the header if it is a new csv file
+func (fc *fileClient) open() error {
+	path := fc.currentPath()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s, detail: %s", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat file %s, detail: %s", path, err)
+	}
+	fc.file = f
+	fc.size = info.Size()
+	fc.openedAt = time.Now()
+
+	if fc.csv && fc.size == 0 {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(fileCSVHeader)
+		w.Flush()
+		n, err := f.Write(buf.Bytes())
+		fc.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write header to %s, detail: %s", path, err)
+		}
+	}
+	return nil
+}
+
+func (fc *fileClient) rotationDue(incoming int64) bool {
+	if fc.size == 0 {
+		return false
+	}
+	if fc.size+incoming > fc.maxSize {
+		return true
+	}
+	return fc.interval != 0 && time.Since(fc.openedAt) >= fc.interval
+}
+
+// rotate renames the current file with the time it is rotated, then opens a new current file,
+// the rotated files are compressed and pruned in background off the write path
+func (fc *fileClient) rotate() error {
+	if err := fc.file.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s, detail: %s", fc.file.Name(), err)
+	}
+	fc.file = nil
+
+	rotated := filepath.Join(fc.cfg.FileCfg.Dir, fc.cfg.Table+"-"+time.Now().Format(fileTimeLayout)+fc.ext)
+	if err := os.Rename(fc.currentPath(), rotated); err != nil {
+		return fmt.Errorf("failed to rotate file %s, detail: %s", fc.currentPath(), err)
+	}
+	if err := fc.open(); err != nil {
+		return err
+	}
+
+	fc.compacts.Add(1)
+	go func() {
+		defer fc.compacts.Done()
+		fc.compact.Lock()
+		defer fc.compact.Unlock()
+		if err := fc.compactRotated(rotated); err != nil {
+			logrus.Warnf("failed to compact rotated file %s, detail: %s", rotated, err)
+		}
+	}()
+	return nil
+}
+
+// compactRotated compresses the rotated file if required, and prunes the rotated files
+func (fc *fileClient) compactRotated(rotated string) error {
+	if fc.cfg.FileCfg.Gzip {
+		if err := gzipFile(rotated); err != nil {
+			return err
+		}
+	}
+	return fc.prune()
+}
+
+// prune removes the oldest rotated files beyond max files, files of other tables in the directory are left alone
+func (fc *fileClient) prune() error {
+	if fc.cfg.FileCfg.MaxFiles == 0 {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(fc.cfg.FileCfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to list directory %s, detail: %s", fc.cfg.FileCfg.Dir, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && fc.isRotated(entry.Name()) {
+			files = append(files, filepath.Join(fc.cfg.FileCfg.Dir, entry.Name()))
+		}
+	}
+	if len(files) <= int(fc.cfg.FileCfg.MaxFiles) {
+		return nil
+	}
+	sort.Strings(files)
+	for _, f := range files[:len(files)-int(fc.cfg.FileCfg.MaxFiles)] {
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("failed to remove rotated file %s, detail: %s", f, err)
+		}
+	}
+	return nil
+}
+
+// isRotated tells if the file is named [table]-[time][ext] or [table]-[time][ext].gz by rotate
+func (fc *fileClient) isRotated(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	prefix := fc.cfg.Table + "-"
+	if len(name) != len(prefix)+len(fileTimeLayout)+len(fc.ext) ||
+		!strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, fc.ext) {
+		return false
+	}
+	_, err := time.Parse(fileTimeLayout, name[len(prefix):len(name)-len(fc.ext)])
+	return err == nil
+}
+
+// gzipFile compresses the file into [path].gz, then removes it
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s, detail: %s", path, err)
+	}
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("failed to create file %s.gz, detail: %s", path, err)
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	src.Close()
+	if err != nil {
+		os.Remove(path + ".gz")
+		return fmt.Errorf("failed to compress file %s, detail: %s", path, err)
+	}
+	return os.Remove(path)
+}
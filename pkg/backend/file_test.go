@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFileClient creates a file client writing to a temporary directory, removed once the test ends
+func newTestFileClient(t *testing.T, fcfg config.FileConfig) *fileClient {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	fcfg.Dir = dir
+	cli, err := createFileClient(config.BackendConfig{Table: "traffic", FileCfg: fcfg})
+	if err != nil {
+		t.Fatalf("failed to create client, detail: %s", err)
+	}
+	if err := cli.Connect(); err != nil {
+		t.Fatalf("failed to connect, detail: %s", err)
+	}
+	return cli.(*fileClient)
+}
+
+// readFile reads the file, decompressed if it is gzipped
+func readFile(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var data []byte
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to decompress %s, detail: %s", path, err)
+		}
+		data, err = ioutil.ReadAll(zr)
+	} else {
+		data, err = ioutil.ReadAll(f)
+	}
+	if err != nil {
+		t.Fatalf("failed to read %s, detail: %s", path, err)
+	}
+	return string(data)
+}
+
+func TestFileEncode(t *testing.T) {
+	records := []*entity.TrafficRecord{
+		{Timestamp: 1622534400, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.3", Protocol: "TCP",
+			DstPort: 443, Direction: "egress", Size: 1500, Packets: 3},
+		{Timestamp: 1622534401, ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::3", Protocol: "UDP",
+			SrcPort: 53, Direction: "ingress", Size: 80, Packets: 1, Tenant: "team-a", ProbeID: "id-1",
+			Hostname: "host", Node: "node-1", Pod: "pod-1", Namespace: "ns", Labels: map[string]string{"app": "web"}},
+	}
+	tests := []struct {
+		format string
+		path   string
+		want   string
+	}{
+		{"jsonl", "traffic.jsonl", `{"timestamp":1622534400,"probeIP":"10.0.0.1","srcIP":"10.0.0.2","dstIP":"10.0.0.3","size":1500,"protocol":"TCP","srcPort":0,"dstPort":443,"direction":"egress","packets":3}
+{"timestamp":1622534401,"probeIP":"fd00::1","srcIP":"fd00::2","dstIP":"fd00::3","size":80,"protocol":"UDP","srcPort":53,"dstPort":0,"direction":"ingress","packets":1,"tenant":"team-a","probeID":"id-1","hostname":"host","node":"node-1","pod":"pod-1","namespace":"ns","labels":{"app":"web"}}
+`},
+		{"csv", "traffic.csv", `timestamp,probeIP,srcIP,dstIP,protocol,srcPort,dstPort,direction,size,packets,tenant,probeID,hostname,node,pod,namespace,labels
+1622534400,10.0.0.1,10.0.0.2,10.0.0.3,TCP,0,443,egress,1500,3,,,,,,,
+1622534401,fd00::1,fd00::2,fd00::3,UDP,53,0,ingress,80,1,team-a,id-1,host,node-1,pod-1,ns,"{""app"":""web""}"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			fc := newTestFileClient(t, config.FileConfig{Format: tt.format})
+			if err := fc.WriteBatch(records); err != nil {
+				t.Fatalf("failed to write, detail: %s", err)
+			}
+			if err := fc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, filepath.Join(fc.cfg.FileCfg.Dir, tt.path)); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileRotate(t *testing.T) {
+	tests := []struct {
+		name     string
+		gzip     bool
+		maxSize  int64
+		interval time.Duration
+	}{
+		{name: "by size", maxSize: 1},
+		{name: "by size gzipped", gzip: true, maxSize: 1},
+		{name: "by interval", maxSize: 1 << 20, interval: time.Millisecond},
+	}
+	// files of other tables, and not rotated by the file backend, are never pruned
+	others := []string{"traffic-v2-20210601-080000.000000.jsonl", "traffic-v2-20210601-080000.000000.jsonl.gz",
+		"traffic-v2.jsonl", "traffic-backup.jsonl", "traffic-20210601.jsonl", "other.jsonl"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := newTestFileClient(t, config.FileConfig{Gzip: tt.gzip, MaxFiles: 2})
+			fc.maxSize = tt.maxSize
+			fc.interval = tt.interval
+			dir := fc.cfg.FileCfg.Dir
+			for _, name := range others {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("keep\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var batches []string
+			for i := 0; i < 4; i++ {
+				record := &entity.TrafficRecord{Timestamp: int64(i), Protocol: "TCP", Direction: "egress"}
+				s, _ := record.ToJSONString()
+				batches = append(batches, s+"\n")
+				time.Sleep(2 * time.Millisecond)
+				if err := fc.WriteBatch([]*entity.TrafficRecord{record}); err != nil {
+					t.Fatalf("failed to write batch %d, detail: %s", i, err)
+				}
+			}
+			if err := fc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rotated, rest []string
+			for _, entry := range entries {
+				if fc.isRotated(entry.Name()) {
+					rotated = append(rotated, entry.Name())
+				} else {
+					rest = append(rest, entry.Name())
+				}
+			}
+			wantRest := append([]string{"traffic.jsonl"}, others...)
+			sort.Strings(wantRest)
+			if !reflect.DeepEqual(rest, wantRest) {
+				t.Errorf("got files %v besides the rotated, want %v", rest, wantRest)
+			}
+			// the oldest rotated file is pruned, the newest two are kept
+			if len(rotated) != 2 {
+				t.Fatalf("got rotated files %v, want 2", rotated)
+			}
+			for i, name := range rotated {
+				if strings.HasSuffix(name, ".gz") != tt.gzip {
+					t.Errorf("rotated file %s, want gzipped %v", name, tt.gzip)
+				}
+				if got := readFile(t, filepath.Join(dir, name)); got != batches[i+1] {
+					t.Errorf("rotated file %s: got %q, want %q", name, got, batches[i+1])
+				}
+			}
+			if got := readFile(t, filepath.Join(dir, "traffic.jsonl")); got != batches[3] {
+				t.Errorf("current file: got %q, want %q", got, batches[3])
+			}
+		})
+	}
+}
+
+func TestFileIsRotated(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"traffic-20210601-080000.123456.jsonl", true},
+		{"traffic-20210601-080000.123456.jsonl.gz", true},
+		{"traffic.jsonl", false},
+		{"traffic-20210601-080000.123456.csv", false},
+		{"traffic-v2-20210601-080000.123456.jsonl", false},
+		{"traffic-20211301-080000.123456.jsonl", false},
+		{"traffic-20210601-080000.jsonl", false},
+		{"traffic-20210601-080000.123456.jsonl.bak", false},
+	}
+	fc := &fileClient{cfg: config.BackendConfig{Table: "traffic"}, ext: ".jsonl"}
+	for _, tt := range tests {
+		if got := fc.isRotated(tt.name); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
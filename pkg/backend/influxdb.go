@@ -2,11 +2,12 @@ package backend
 
 import (
 	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/entity"
+	"fmt"
 	"time"
 
 	iclient "github.com/influxdata/influxdb1-client/v2"
-	"github.com/sirupsen/logrus"
 )
 
 type influxClient struct {
@@ -14,7 +15,11 @@ type influxClient struct {
 	cfg    config.BackendConfig
 }
 
-func createInfluxClient(cfg config.BackendConfig) *influxClient {
+func init() {
+	Register(constant.BackendInfluxDB, createInfluxClient)
+}
+
+func createInfluxClient(cfg config.BackendConfig) (DataBackend, error) {
 	cli, err := iclient.NewHTTPClient(
 		iclient.HTTPConfig{
 			Addr:     cfg.InfluxCfg.Host,
@@ -24,13 +29,13 @@ func createInfluxClient(cfg config.BackendConfig) *influxClient {
 		},
 	)
 	if err != nil {
-		logrus.Fatalf("failed to create influxDB client, detail: %s", err)
+		return nil, fmt.Errorf("failed to create influxDB client, detail: %s", err)
 	}
 	ret := &influxClient{
 		client: cli,
 		cfg:    cfg,
 	}
-	return ret
+	return ret, nil
 }
 
 func (ic *influxClient) Connect() error {
@@ -43,26 +48,7 @@ func (ic *influxClient) Close() error {
 }
 
 func (ic *influxClient) Write(record *entity.TrafficRecord) error {
-	bps, _ := iclient.NewBatchPoints(iclient.BatchPointsConfig{
-		Database:  ic.cfg.Database,
-		Precision: "s",
-	})
-	// makeup point
-	tags := map[string]string{
-		"probeIP": record.ProbeIP,
-		"srcIP":   record.SrcIP,
-		"dstIP":   record.DstIP,
-	}
-	fields := map[string]interface{}{
-		"size": record.Size,
-	}
-	pt, err := iclient.NewPoint(ic.cfg.Table, tags, fields, time.Unix(record.Timestamp, 0))
-
-	if err != nil {
-		return err
-	}
-	bps.AddPoint(pt)
-	return ic.client.Write(bps)
+	return ic.WriteBatch([]*entity.TrafficRecord{record})
 }
 
 func (ic *influxClient) WriteBatch(record []*entity.TrafficRecord) error {
@@ -72,17 +58,7 @@ func (ic *influxClient) WriteBatch(record []*entity.TrafficRecord) error {
 	})
 
 	for _, p := range record {
-		// makeup point
-		tags := map[string]string{
-			"probeIP": p.ProbeIP,
-			"srcIP":   p.SrcIP,
-			"dstIP":   p.DstIP,
-		}
-		fields := map[string]interface{}{
-			"size": p.Size,
-		}
-		pt, err := iclient.NewPoint(ic.cfg.Table, tags, fields, time.Unix(p.Timestamp, 0))
-
+		pt, err := ic.makePoint(p)
 		if err != nil {
 			return err
 		}
@@ -91,3 +67,12 @@ func (ic *influxClient) WriteBatch(record []*entity.TrafficRecord) error {
 
 	return ic.client.Write(bps)
 }
+
+func (ic *influxClient) makePoint(record *entity.TrafficRecord) (*iclient.Point, error) {
+	tags := recordTags(record)
+	fields := map[string]interface{}{
+		"size":    record.Size,
+		"packets": record.Packets,
+	}
+	return iclient.NewPoint(ic.cfg.Table, tags, fields, time.Unix(record.Timestamp, 0))
+}
@@ -0,0 +1,239 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var influx2Precisions = map[string]int64{
+	"s":  1,
+	"ms": 1e3,
+	"us": 1e6,
+	"ns": 1e9,
+}
+
+var lineProtocolEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+var measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+// influx2Point sums of the records in a series at a timestamp
+type influx2Point struct {
+	timestamp int64 // in second
+	size      uint64
+	packets   uint64
+}
+
+// influx2Client writes the records in line protocol to the write API of InfluxDB 2.x.
+// A point of the same series and timestamp written again overwrites the former one, so with the tags reduced, the
+// sums of the recent points written are kept, and the records are added to them, so that the points written carry
+// the sums across batches. Records older than Influx2SumWindow by the latest one, or across restarts of center,
+// are not summed up with the written points.
+type influx2Client struct {
+	client     *http.Client
+	cfg        config.BackendConfig
+	writeURL   string
+	tags       []string // sorted tag names
+	multiplier int64    // converts timestamp in second to the precision
+	reduced    bool     // tags are reduced from selectTags(nil), so records of different series may share the same point
+	mu         sync.Mutex
+	sums       map[string]*influx2Point // sums of the recent points written, by series key and timestamp
+	latest     int64                    // latest timestamp in sums
+}
+
+func init() {
+	Register(constant.BackendInfluxDB2, createInflux2Client)
+}
+
+func createInflux2Client(cfg config.BackendConfig) (DataBackend, error) {
+	icfg := cfg.Influx2Cfg
+	precision := icfg.Precision
+	if precision == "" {
+		precision = "s"
+	}
+	multiplier, ok := influx2Precisions[precision]
+	if !ok {
+		return nil, fmt.Errorf("invalid precision %s, use s, ms, us or ns", precision)
+	}
+	bucket := icfg.Bucket
+	if bucket == "" {
+		bucket = cfg.Database
+	}
+
+	tags, err := selectTags(icfg.Tags)
+	if err != nil {
+		return nil, err
+	}
+	reduced := false
+	all, _ := selectTags(nil)
+	for _, tag := range all {
+		if i := sort.SearchStrings(tags, tag); i == len(tags) || tags[i] != tag {
+			reduced = true
+		}
+	}
+
+	query := url.Values{}
+	query.Set("org", icfg.Org)
+	query.Set("bucket", bucket)
+	query.Set("precision", precision)
+	ret := &influx2Client{
+		client:     &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		cfg:        cfg,
+		writeURL:   strings.TrimRight(icfg.URL, "/") + "/api/v2/write?" + query.Encode(),
+		tags:       tags,
+		multiplier: multiplier,
+		reduced:    reduced,
+		sums:       make(map[string]*influx2Point),
+	}
+	return ret, nil
+}
+
+func (ic *influx2Client) Connect() error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(ic.cfg.Influx2Cfg.URL, "/")+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to ping influxdb, status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (ic *influx2Client) Close() error {
+	ic.client.CloseIdleConnections()
+	return nil
+}
+
+func (ic *influx2Client) Write(record *entity.TrafficRecord) error {
+	return ic.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch writes the records, the sums of points are kept only if written
+func (ic *influx2Client) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	body, points, err := ic.encode(records)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, ic.writeURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+ic.cfg.Influx2Cfg.Token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if ic.cfg.Influx2Cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to write to influxdb, status: %s, detail: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	ic.keepSums(points)
+	return nil
+}
+
+// keepSums keeps the sums of points written if tags are reduced, and drops those out of Influx2SumWindow
+func (ic *influx2Client) keepSums(points map[string]*influx2Point) {
+	if !ic.reduced {
+		return
+	}
+	for key, p := range points {
+		ic.sums[key] = p
+		if p.timestamp > ic.latest {
+			ic.latest = p.timestamp
+		}
+	}
+	for key, p := range ic.sums {
+		if p.timestamp < ic.latest-constant.Influx2SumWindow {
+			delete(ic.sums, key)
+		}
+	}
+}
+
+// encode encodes the records in line protocol, records with the same tags and timestamp are summed up, and added to
+// the sums of points written if tags are reduced, otherwise they overwrite each other in influxdb.
+// The points encoded are returned as well
+func (ic *influx2Client) encode(records []*entity.TrafficRecord) (*bytes.Buffer, map[string]*influx2Point, error) {
+	points := make(map[string]*influx2Point)
+	keys := make([]string, 0, len(records))
+	for _, record := range records {
+		key := ic.seriesKey(record) + " " + strconv.FormatInt(record.Timestamp*ic.multiplier, 10)
+		p, ok := points[key]
+		if !ok {
+			p = &influx2Point{timestamp: record.Timestamp}
+			if written, ok := ic.sums[key]; ok {
+				*p = *written
+			}
+			points[key] = p
+			keys = append(keys, key)
+		}
+		p.size += record.Size
+		p.packets += record.Packets
+	}
+
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	var zw *gzip.Writer
+	if ic.cfg.Influx2Cfg.Gzip {
+		zw = gzip.NewWriter(buf)
+		w = zw
+	}
+	for _, key := range keys {
+		i := strings.LastIndexByte(key, ' ')
+		p := points[key]
+		if _, err := fmt.Fprintf(w, "%s size=%di,packets=%di%s\n", key[:i], p.size, p.packets, key[i:]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return buf, points, nil
+}
+
+// seriesKey return the measurement and the tags in line protocol, empty tags are omitted
+func (ic *influx2Client) seriesKey(record *entity.TrafficRecord) string {
+	var sb strings.Builder
+	sb.WriteString(measurementEscaper.Replace(ic.cfg.Table))
+	values := recordTags(record)
+	for _, tag := range ic.tags {
+		if values[tag] == "" {
+			continue
+		}
+		sb.WriteString(",")
+		sb.WriteString(tag)
+		sb.WriteString("=")
+		sb.WriteString(lineProtocolEscaper.Replace(values[tag]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,211 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeInflux2 is an influxdb 2.x stand-in keeping the lines written, it fails the writes while failing is set
+type fakeInflux2 struct {
+	*httptest.Server
+	mu      sync.Mutex
+	failing bool
+	lines   []string
+}
+
+func newFakeInflux2(t *testing.T) *fakeInflux2 {
+	fi := &fakeInflux2{}
+	fi.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fi.mu.Lock()
+		defer fi.mu.Unlock()
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v2/write" || q.Get("org") != "org" || q.Get("bucket") != "bucket" || q.Get("precision") != "s" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if fi.failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fi.lines = append(fi.lines, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(fi.Close)
+	return fi
+}
+
+// takeLines returns the lines written since last taken
+func (fi *fakeInflux2) takeLines() []string {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	ret := fi.lines
+	fi.lines = nil
+	return ret
+}
+
+func (fi *fakeInflux2) setFailing(failing bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.failing = failing
+}
+
+func TestInflux2WriteBatch(t *testing.T) {
+	record := func(ts int64, srcIP string, dstPort uint16, size uint64) *entity.TrafficRecord {
+		return &entity.TrafficRecord{Timestamp: ts, ProbeIP: "10.0.0.1", SrcIP: srcIP, DstIP: "10.0.0.9", Protocol: "TCP",
+			DstPort: dstPort, Direction: "ingress", Size: size, Packets: 1}
+	}
+	type batch struct {
+		records []*entity.TrafficRecord
+		failing bool
+		want    []string
+	}
+	tests := []struct {
+		name    string
+		tags    []string
+		gzip    bool
+		batches []batch
+	}{
+		{
+			name: "all tags",
+			batches: []batch{{
+				records: []*entity.TrafficRecord{record(100, "10.0.0.2", 80, 10), record(100, "10.0.0.2", 80, 5), record(100, "10.0.0.3", 80, 7)},
+				want: []string{
+					"traffic,direction=ingress,dstIP=10.0.0.9,dstPort=80,probeIP=10.0.0.1,protocol=TCP,srcIP=10.0.0.2,srcPort=0 size=15i,packets=2i 100",
+					"traffic,direction=ingress,dstIP=10.0.0.9,dstPort=80,probeIP=10.0.0.1,protocol=TCP,srcIP=10.0.0.3,srcPort=0 size=7i,packets=1i 100",
+				},
+			}},
+		},
+		{
+			name: "reduced tags summed across batches",
+			tags: []string{"probeIP", "dstPort"},
+			gzip: true,
+			batches: []batch{
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.2", 80, 10), record(100, "10.0.0.3", 80, 5)},
+					want:    []string{"traffic,dstPort=80,probeIP=10.0.0.1 size=15i,packets=2i 100"},
+				},
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.4", 80, 1), record(101, "10.0.0.2", 443, 2)},
+					want: []string{
+						"traffic,dstPort=80,probeIP=10.0.0.1 size=16i,packets=3i 100",
+						"traffic,dstPort=443,probeIP=10.0.0.1 size=2i,packets=1i 101",
+					},
+				},
+			},
+		},
+		{
+			name: "workload label tag",
+			tags: []string{"probeIP", "labels.app"},
+			batches: []batch{{
+				records: []*entity.TrafficRecord{
+					func() *entity.TrafficRecord {
+						r := record(100, "10.0.0.2", 80, 10)
+						r.Labels = map[string]string{"app": "web server", "tier": "front"}
+						return r
+					}(),
+					record(100, "10.0.0.3", 80, 5),
+				},
+				want: []string{
+					`traffic,labels.app=web\ server,probeIP=10.0.0.1 size=10i,packets=1i 100`,
+					"traffic,probeIP=10.0.0.1 size=5i,packets=1i 100",
+				},
+			}},
+		},
+		{
+			name: "reduced tags not summed with failed batch",
+			tags: []string{"probeIP"},
+			batches: []batch{
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.2", 80, 10)},
+					want:    []string{"traffic,probeIP=10.0.0.1 size=10i,packets=1i 100"},
+				},
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.3", 80, 3)},
+					failing: true,
+				},
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.3", 80, 3)},
+					want:    []string{"traffic,probeIP=10.0.0.1 size=13i,packets=2i 100"},
+				},
+			},
+		},
+		{
+			name: "reduced tags out of window",
+			tags: []string{"probeIP"},
+			batches: []batch{
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.2", 80, 10), record(1000, "10.0.0.2", 80, 1)},
+					want: []string{
+						"traffic,probeIP=10.0.0.1 size=10i,packets=1i 100",
+						"traffic,probeIP=10.0.0.1 size=1i,packets=1i 1000",
+					},
+				},
+				{
+					records: []*entity.TrafficRecord{record(100, "10.0.0.3", 80, 3)},
+					want:    []string{"traffic,probeIP=10.0.0.1 size=3i,packets=1i 100"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fi := newFakeInflux2(t)
+			cli, err := createInflux2Client(config.BackendConfig{
+				Table:   "traffic",
+				Timeout: 5,
+				Influx2Cfg: config.Influx2Config{
+					URL: fi.URL, Org: "org", Bucket: "bucket", Token: "secret", Gzip: tt.gzip, Tags: tt.tags,
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to create client, detail: %s", err)
+			}
+			if err := cli.Connect(); err != nil {
+				t.Fatalf("failed to connect, detail: %s", err)
+			}
+			defer cli.Close()
+
+			for i, b := range tt.batches {
+				fi.setFailing(b.failing)
+				err := cli.WriteBatch(b.records)
+				if (err != nil) != b.failing {
+					t.Fatalf("batch %d: got error %v, want failing %v", i, err, b.failing)
+				}
+				if got := fi.takeLines(); !reflect.DeepEqual(got, b.want) {
+					t.Errorf("batch %d: got lines\n%s\nwant\n%s", i, strings.Join(got, "\n"), strings.Join(b.want, "\n"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/transmit/message"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// kafkaClient publishes the records to a kafka topic, keyed by probe IP so that records from one probe keep their order
+type kafkaClient struct {
+	writer *kafka.Writer
+	cfg    config.BackendConfig
+	encode func(record *entity.TrafficRecord) ([]byte, error)
+}
+
+func init() {
+	Register(constant.BackendKafka, createKafkaClient)
+}
+
+func createKafkaClient(cfg config.BackendConfig) (DataBackend, error) {
+	kc := cfg.KafkaCfg
+	if len(kc.Brokers) == 0 {
+		return nil, fmt.Errorf("no kafka broker given")
+	}
+	topic := kc.Topic
+	if topic == "" {
+		topic = cfg.Table
+	}
+
+	var encode func(record *entity.TrafficRecord) ([]byte, error)
+	switch strings.ToLower(kc.Encoding) {
+	case "", "json":
+		encode = encodeKafkaJSON
+	case "protobuf":
+		encode = encodeKafkaProtobuf
+	default:
+		return nil, fmt.Errorf("invalid kafka encoding %s, should be json or protobuf", kc.Encoding)
+	}
+
+	var acks kafka.RequiredAcks
+	switch strings.ToLower(kc.RequiredAcks) {
+	case "", "all":
+		acks = kafka.RequireAll
+	case "one":
+		acks = kafka.RequireOne
+	case "none":
+		acks = kafka.RequireNone
+	default:
+		return nil, fmt.Errorf("invalid kafka required acks %s, should be none, one or all", kc.RequiredAcks)
+	}
+
+	var compression kafka.Compression
+	switch strings.ToLower(kc.Compression) {
+	case "", "none":
+	case "gzip":
+		compression = kafka.Gzip
+	case "snappy":
+		compression = kafka.Snappy
+	case "lz4":
+		compression = kafka.Lz4
+	case "zstd":
+		compression = kafka.Zstd
+	default:
+		return nil, fmt.Errorf("invalid kafka compression %s, should be none, gzip, snappy, lz4 or zstd", kc.Compression)
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(kc.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    kc.BatchSize,
+		BatchBytes:   kc.BatchBytes,
+		BatchTimeout: time.Duration(kc.BatchTimeout) * time.Millisecond,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		RequiredAcks: acks,
+		Compression:  compression,
+	}
+	ret := &kafkaClient{
+		writer: writer,
+		cfg:    cfg,
+		encode: encode,
+	}
+	return ret, nil
+}
+
+// Connect checks whether any of the brokers is reachable
+func (kc *kafkaClient) Connect() error {
+	var lastErr error
+	for _, broker := range kc.cfg.KafkaCfg.Brokers {
+		ctx, cancel := kc.context()
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return conn.Close()
+	}
+	return fmt.Errorf("failed to connect to kafka, detail: %s", lastErr)
+}
+
+// Close flushes the pending messages and closes the producer
+func (kc *kafkaClient) Close() error {
+	return kc.writer.Close()
+}
+
+func (kc *kafkaClient) Write(record *entity.TrafficRecord) error {
+	return kc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch publishes the records and waits for the acks required
+func (kc *kafkaClient) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	msgs := make([]kafka.Message, 0, len(records))
+	for _, r := range records {
+		value, err := kc.encode(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode record for kafka, detail: %s", err)
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(r.ProbeIP),
+			Value: value,
+			Time:  time.Unix(r.Timestamp, 0),
+		})
+	}
+
+	ctx, cancel := kc.context()
+	defer cancel()
+	if err := kc.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to publish records to kafka, detail: %s", err)
+	}
+	return nil
+}
+
+// context gives a context bounded by the timeout of backend, or the background if timeout is not set
+func (kc *kafkaClient) context() (context.Context, context.CancelFunc) {
+	if kc.cfg.Timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(kc.cfg.Timeout)*time.Second)
+}
+
+func encodeKafkaJSON(record *entity.TrafficRecord) ([]byte, error) {
+	s, err := record.ToJSONString()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// encodeKafkaProtobuf encodes the record in TransmitRequest with its tenant and probe attached,
+// so consumers can decode it with pkg/transmit/message/message.proto
+func encodeKafkaProtobuf(record *entity.TrafficRecord) ([]byte, error) {
+	req := message.NewTransmitRequest(record)
+	req.Tenant = record.Tenant
+	if record.ProbeID != "" {
+		req.Probe = message.NewProbeInfo(&entity.ProbeInfo{
+			ID:        record.ProbeID,
+			Hostname:  record.Hostname,
+			Node:      record.Node,
+			Pod:       record.Pod,
+			Namespace: record.Namespace,
+			Labels:    record.Labels,
+		})
+	}
+	return proto.Marshal(req)
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/transmit/message"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeKafkaJSON decodes the message as a consumer of json encoding does
+func decodeKafkaJSON(data []byte) (*entity.TrafficRecord, error) {
+	ret := &entity.TrafficRecord{}
+	return ret, json.Unmarshal(data, ret)
+}
+
+// decodeKafkaProtobuf decodes the message as a consumer of protobuf encoding does
+func decodeKafkaProtobuf(data []byte) (*entity.TrafficRecord, error) {
+	req := &message.TransmitRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return nil, err
+	}
+	ret := req.ToTrafficRecord()
+	ret.Tenant = req.Tenant
+	if req.Probe != nil {
+		ret.SetProbeInfo(req.Probe.ToProbeInfo())
+	}
+	return ret, nil
+}
+
+func TestKafkaEncode(t *testing.T) {
+	records := map[string]*entity.TrafficRecord{
+		"without identity": {Timestamp: 1622534400, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.3",
+			Protocol: "TCP", DstPort: 443, Direction: "egress", Size: 1500, Packets: 3},
+		"with identity": {Timestamp: 1622534401, ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::3",
+			Protocol: "UDP", SrcPort: 53, Direction: "ingress", Size: 80, Packets: 1, Tenant: "team-a", ProbeID: "id-1",
+			Hostname: "host", Node: "node-1", Pod: "pod-1", Namespace: "ns", Labels: map[string]string{"app": "web"}},
+	}
+	encodings := []struct {
+		name   string
+		encode func(*entity.TrafficRecord) ([]byte, error)
+		decode func([]byte) (*entity.TrafficRecord, error)
+	}{
+		{"json", encodeKafkaJSON, decodeKafkaJSON},
+		{"protobuf", encodeKafkaProtobuf, decodeKafkaProtobuf},
+	}
+	for _, enc := range encodings {
+		for name, record := range records {
+			t.Run(enc.name+"/"+name, func(t *testing.T) {
+				data, err := enc.encode(record)
+				if err != nil {
+					t.Fatalf("failed to encode, detail: %s", err)
+				}
+				got, err := enc.decode(data)
+				if err != nil {
+					t.Fatalf("failed to decode, detail: %s", err)
+				}
+				if !reflect.DeepEqual(got, record) {
+					t.Errorf("got %+v, want %+v", got, record)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateKafkaClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.KafkaConfig
+		wantErr bool
+	}{
+		{"defaults", config.KafkaConfig{Brokers: []string{"localhost:9092"}}, false},
+		{"all options", config.KafkaConfig{Brokers: []string{"localhost:9092"}, Encoding: "protobuf", RequiredAcks: "one", Compression: "zstd"}, false},
+		{"no broker", config.KafkaConfig{}, true},
+		{"invalid encoding", config.KafkaConfig{Brokers: []string{"localhost:9092"}, Encoding: "avro"}, true},
+		{"invalid acks", config.KafkaConfig{Brokers: []string{"localhost:9092"}, RequiredAcks: "two"}, true},
+		{"invalid compression", config.KafkaConfig{Brokers: []string{"localhost:9092"}, Compression: "brotli"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createKafkaClient(config.BackendConfig{Table: "traffic", KafkaCfg: tt.cfg})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
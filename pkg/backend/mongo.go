@@ -2,11 +2,12 @@ package backend
 
 import (
 	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/entity"
 	"context"
+	"fmt"
 	"time"
 
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -16,16 +17,20 @@ type MongoClient struct {
 	cfg    config.BackendConfig
 }
 
-func createMongoClient(cfg config.BackendConfig) *MongoClient {
+func init() {
+	Register(constant.BackendMongoDB, createMongoClient)
+}
+
+func createMongoClient(cfg config.BackendConfig) (DataBackend, error) {
 	cli, err := mongo.NewClient(options.Client().ApplyURI(cfg.MongoCfg.MongoURI))
 	if err != nil {
-		logrus.Fatalf("failed to create mongoDB client, detail: %s", err)
+		return nil, fmt.Errorf("failed to create mongoDB client, detail: %s", err)
 	}
 	ret := &MongoClient{
 		client: cli,
 		cfg:    cfg,
 	}
-	return ret
+	return ret, nil
 }
 
 func (mc *MongoClient) Connect() error {
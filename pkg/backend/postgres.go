@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var postgresColumns = []string{"time", "probe_ip", "src_ip", "dst_ip", "protocol", "src_port", "dst_port", "direction",
+	"size", "packets", "tenant", "probe_id", "hostname", "node", "pod", "namespace", "labels"}
+
+// postgresClient writes the records into a table of PostgreSQL by COPY, the table can be a hypertable of TimescaleDB
+type postgresClient struct {
+	db     *sql.DB
+	cfg    config.BackendConfig
+	schema string
+}
+
+func init() {
+	Register(constant.BackendPostgres, createPostgresClient)
+}
+
+func createPostgresClient(cfg config.BackendConfig) (DataBackend, error) {
+	db, err := sql.Open("postgres", cfg.PgCfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres client, detail: %s", err)
+	}
+	ret := &postgresClient{
+		db:     db,
+		cfg:    cfg,
+		schema: cfg.PgCfg.Schema,
+	}
+	if ret.schema == "" {
+		ret.schema = "public"
+	}
+	return ret, nil
+}
+
+// Connect connects to postgres, and creates the table if not exists
+func (pc *postgresClient) Connect() error {
+	ctx, cancel := pc.makeContextWithTimeout()
+	defer cancel()
+	if err := pc.db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	ipType := "text"
+	if pc.cfg.PgCfg.InetColumns {
+		ipType = "inet"
+	}
+	table := pc.qualifiedTable()
+	stmts := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(pc.schema)),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	time timestamptz NOT NULL,
+	probe_ip %[2]s,
+	src_ip %[2]s,
+	dst_ip %[2]s,
+	protocol text NOT NULL,
+	src_port integer NOT NULL,
+	dst_port integer NOT NULL,
+	direction text NOT NULL,
+	size bigint NOT NULL,
+	packets bigint NOT NULL,
+	tenant text,
+	probe_id text,
+	hostname text,
+	node text,
+	pod text,
+	namespace text,
+	labels jsonb
+)`, table, ipType),
+	}
+	if pc.cfg.PgCfg.Hypertable {
+		stmts = append(stmts, fmt.Sprintf("SELECT create_hypertable(%s, 'time', if_not_exists => TRUE)",
+			pq.QuoteLiteral(table)))
+	} else {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (time DESC)",
+			pq.QuoteIdentifier(pc.cfg.Table+"_time_idx"), table))
+	}
+	for _, stmt := range stmts {
+		if _, err := pc.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create table %s, detail: %s", table, err)
+		}
+	}
+	return nil
+}
+
+func (pc *postgresClient) Close() error {
+	return pc.db.Close()
+}
+
+func (pc *postgresClient) Write(record *entity.TrafficRecord) error {
+	return pc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+func (pc *postgresClient) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ctx, cancel := pc.makeContextWithTimeout()
+	defer cancel()
+
+	tx, err := pc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(pc.schema, pc.cfg.Table, postgresColumns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		row, err := copyRow(r)
+		if err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+	if err = stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyRow return the values of record copied into postgresColumns
+func copyRow(r *entity.TrafficRecord) ([]interface{}, error) {
+	labels, err := labelsJSON(r.Labels)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{time.Unix(r.Timestamp, 0).UTC(), nullIfEmpty(r.ProbeIP), nullIfEmpty(r.SrcIP),
+		nullIfEmpty(r.DstIP), r.Protocol, int32(r.SrcPort), int32(r.DstPort), r.Direction, int64(r.Size), int64(r.Packets),
+		nullIfEmpty(r.Tenant), nullIfEmpty(r.ProbeID), nullIfEmpty(r.Hostname), nullIfEmpty(r.Node),
+		nullIfEmpty(r.Pod), nullIfEmpty(r.Namespace), labels}, nil
+}
+
+func (pc *postgresClient) qualifiedTable() string {
+	return pq.QuoteIdentifier(pc.schema) + "." + pq.QuoteIdentifier(pc.cfg.Table)
+}
+
+func (pc *postgresClient) makeContextWithTimeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(pc.cfg.Timeout)*time.Second)
+}
+
+// nullIfEmpty stores empty IP as NULL, which is not a valid inet
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// labelsJSON encodes the labels in JSON for jsonb column, or NULL if no label
+func labelsJSON(labels map[string]string) (interface{}, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode labels, detail: %s", err)
+	}
+	return string(b), nil
+}
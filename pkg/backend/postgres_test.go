@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCopyRow(t *testing.T) {
+	ts := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record *entity.TrafficRecord
+		want   []interface{}
+	}{
+		{
+			name: "without identity",
+			record: &entity.TrafficRecord{Timestamp: ts.Unix(), ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.3",
+				Protocol: "TCP", SrcPort: 0, DstPort: 443, Direction: "egress", Size: 1500, Packets: 3},
+			want: []interface{}{ts, "10.0.0.1", "10.0.0.2", "10.0.0.3", "TCP", int32(0), int32(443), "egress",
+				int64(1500), int64(3), nil, nil, nil, nil, nil, nil, nil},
+		},
+		{
+			name: "with identity",
+			record: &entity.TrafficRecord{Timestamp: ts.Unix(), ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::3",
+				Protocol: "UDP", SrcPort: 53, DstPort: 0, Direction: "ingress", Size: 80, Packets: 1, Tenant: "team-a",
+				ProbeID: "id-1", Hostname: "host", Node: "node-1", Pod: "pod-1", Namespace: "ns",
+				Labels: map[string]string{"app": "web", "tier": "front"}},
+			want: []interface{}{ts, "fd00::1", "fd00::2", "fd00::3", "UDP", int32(53), int32(0), "ingress",
+				int64(80), int64(1), "team-a", "id-1", "host", "node-1", "pod-1", "ns", `{"app":"web","tier":"front"}`},
+		},
+		{
+			name:   "empty IPs are NULL",
+			record: &entity.TrafficRecord{Timestamp: ts.Unix(), Protocol: "ICMP", Direction: "local"},
+			want: []interface{}{ts, nil, nil, nil, "ICMP", int32(0), int32(0), "local",
+				int64(0), int64(0), nil, nil, nil, nil, nil, nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := copyRow(tt.record)
+			if err != nil {
+				t.Fatalf("failed to make row, detail: %s", err)
+			}
+			if len(got) != len(postgresColumns) {
+				t.Fatalf("got %d values, want one for each of %d columns", len(got), len(postgresColumns))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got row\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresQualifiedTable(t *testing.T) {
+	tests := []struct {
+		schema string
+		table  string
+		want   string
+	}{
+		{"", "traffic", `"public"."traffic"`},
+		{"metrics", "traffic", `"metrics"."traffic"`},
+		{"my schema", `we"ird`, `"my schema"."we""ird"`},
+	}
+	for _, tt := range tests {
+		cli, err := createPostgresClient(config.BackendConfig{Table: tt.table, PgCfg: config.PostgresConfig{
+			DSN: "postgres://localhost/wakizashi", Schema: tt.schema}})
+		if err != nil {
+			t.Fatalf("failed to create client, detail: %s", err)
+		}
+		if got := cli.(*postgresClient).qualifiedTable(); got != tt.want {
+			t.Errorf("schema %q table %q: got %s, want %s", tt.schema, tt.table, got, tt.want)
+		}
+	}
+}
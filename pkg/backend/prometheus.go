@@ -0,0 +1,258 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promLabelNames names of the labels of record tags, in prometheus' naming convention
+var promLabelNames = map[string]string{
+	"probeIP":   "probe_ip",
+	"srcIP":     "src_ip",
+	"dstIP":     "dst_ip",
+	"protocol":  "protocol",
+	"srcPort":   "src_port",
+	"dstPort":   "dst_port",
+	"direction": "direction",
+	"tenant":    "tenant",
+	"probeID":   "probe_id",
+	"hostname":  "hostname",
+	"node":      "node",
+	"pod":       "pod",
+	"namespace": "namespace",
+}
+
+// promLabelName return the label name of tag, the labels of workload are named like label_app
+func promLabelName(tag string) string {
+	if name, ok := promLabelNames[tag]; ok {
+		return name
+	}
+	return "label_" + promInvalidLabelChars.ReplaceAllString(strings.TrimPrefix(tag, labelTagPrefix), "_")
+}
+
+// promInvalidLabelChars characters not allowed in prometheus label names
+var promInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// promSeries the totals of records sharing the same label values
+type promSeries struct {
+	values  []string
+	bytes   uint64
+	packets uint64
+	updated time.Time
+}
+
+// promClient keeps traffic totals in memory as counters, served in prometheus text format to be scraped.
+// Series not updated in TTL are removed, and records of new series are dropped once max series is reached.
+type promClient struct {
+	mu        sync.Mutex
+	cfg       config.BackendConfig
+	tags      []string
+	labels    []string
+	maxSeries int
+	ttl       time.Duration
+	series    map[string]*promSeries
+	dropped   uint64
+	serv      *http.Server
+	done      chan struct{}
+}
+
+func init() {
+	Register(constant.BackendPrometheus, createPromClient)
+}
+
+func createPromClient(cfg config.BackendConfig) (DataBackend, error) {
+	pcfg := cfg.PromCfg
+	if pcfg.Addr == "" {
+		return nil, fmt.Errorf("no listen address given for prometheus backend")
+	}
+	tags, err := selectTags(pcfg.Labels)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]string, 0, len(tags))
+	seen := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		label := promLabelName(tag)
+		if prev, ok := seen[label]; ok {
+			return nil, fmt.Errorf("tags %s and %s are both exposed as label %s", prev, tag, label)
+		}
+		seen[label] = tag
+		labels = append(labels, label)
+	}
+
+	ret := &promClient{
+		cfg:       cfg,
+		tags:      tags,
+		labels:    labels,
+		maxSeries: int(pcfg.MaxSeries),
+		ttl:       time.Duration(pcfg.SeriesTTL) * time.Second,
+		series:    make(map[string]*promSeries),
+	}
+	if pcfg.MaxSeries == 0 {
+		ret.maxSeries = constant.PrometheusDefaultMaxSeries
+	}
+	if pcfg.SeriesTTL == 0 {
+		ret.ttl = constant.PrometheusDefaultSeriesTTL * time.Second
+	}
+	return ret, nil
+}
+
+// Connect starts serving the metrics, and expiring the stale series in background
+func (pc *promClient) Connect() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.serv != nil {
+		return nil
+	}
+
+	path := pc.cfg.PromCfg.Path
+	if path == "" {
+		path = constant.PrometheusDefaultPath
+	}
+	ln, err := net.Listen("tcp", pc.cfg.PromCfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s, detail: %s", pc.cfg.PromCfg.Addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, pc)
+	pc.serv = &http.Server{Handler: mux}
+	pc.done = make(chan struct{})
+
+	go pc.serv.Serve(ln)
+	go pc.expireLoop(pc.done)
+	return nil
+}
+
+// Close stops serving the metrics, the counters are kept
+func (pc *promClient) Close() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.serv == nil {
+		return nil
+	}
+	close(pc.done)
+	err := pc.serv.Close()
+	pc.serv = nil
+	return err
+}
+
+func (pc *promClient) Write(record *entity.TrafficRecord) error {
+	return pc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+// WriteBatch adds the size and packets of records to the counters of their series
+func (pc *promClient) WriteBatch(records []*entity.TrafficRecord) error {
+	now := time.Now()
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	for _, r := range records {
+		tags := recordTags(r)
+		values := make([]string, 0, len(pc.tags))
+		for _, tag := range pc.tags {
+			values = append(values, tags[tag])
+		}
+		key := strings.Join(values, "\xff")
+
+		s, ok := pc.series[key]
+		if !ok {
+			if len(pc.series) >= pc.maxSeries {
+				pc.expire(now)
+			}
+			if len(pc.series) >= pc.maxSeries {
+				pc.dropped++
+				continue
+			}
+			s = &promSeries{values: values}
+			pc.series[key] = s
+		}
+		s.bytes += r.Size
+		s.packets += r.Packets
+		s.updated = now
+	}
+	return nil
+}
+
+// expire removes the series not updated in TTL, must be called with mu held
+func (pc *promClient) expire(now time.Time) {
+	for key, s := range pc.series {
+		if now.Sub(s.updated) >= pc.ttl {
+			delete(pc.series, key)
+		}
+	}
+}
+
+func (pc *promClient) expireLoop(done chan struct{}) {
+	interval := pc.ttl
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			pc.mu.Lock()
+			pc.expire(now)
+			pc.mu.Unlock()
+		}
+	}
+}
+
+func (pc *promClient) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+
+	pc.mu.Lock()
+	keys := make([]string, 0, len(pc.series))
+	for key := range pc.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("# HELP wakizashi_bytes_total Total bytes of the traffic.\n")
+	buf.WriteString("# TYPE wakizashi_bytes_total counter\n")
+	for _, key := range keys {
+		s := pc.series[key]
+		fmt.Fprintf(&buf, "wakizashi_bytes_total%s %d\n", pc.formatLabels(s.values), s.bytes)
+	}
+	buf.WriteString("# HELP wakizashi_packets_total Total packets of the traffic.\n")
+	buf.WriteString("# TYPE wakizashi_packets_total counter\n")
+	for _, key := range keys {
+		s := pc.series[key]
+		fmt.Fprintf(&buf, "wakizashi_packets_total%s %d\n", pc.formatLabels(s.values), s.packets)
+	}
+	buf.WriteString("# HELP wakizashi_series Count of traffic series kept.\n")
+	buf.WriteString("# TYPE wakizashi_series gauge\n")
+	fmt.Fprintf(&buf, "wakizashi_series %d\n", len(pc.series))
+	buf.WriteString("# HELP wakizashi_series_dropped_total Total records dropped for the series limit.\n")
+	buf.WriteString("# TYPE wakizashi_series_dropped_total counter\n")
+	fmt.Fprintf(&buf, "wakizashi_series_dropped_total %d\n", pc.dropped)
+	pc.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// formatLabels formats the label values like {probe_ip="10.0.0.1",...}
+func (pc *promClient) formatLabels(values []string) string {
+	pairs := make([]string, 0, len(values))
+	for i, v := range values {
+		pairs = append(pairs, pc.labels[i]+`="`+promLabelEscaper.Replace(v)+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// promLabelEscaper escapes label values in prometheus text format
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
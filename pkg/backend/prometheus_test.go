@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPromExposition(t *testing.T) {
+	records := []*entity.TrafficRecord{
+		{Timestamp: 100, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.3", Protocol: "TCP", DstPort: 443,
+			Direction: "egress", Size: 1500, Packets: 3},
+		{Timestamp: 101, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.4", DstIP: "10.0.0.3", Protocol: "TCP", DstPort: 443,
+			Direction: "egress", Size: 500, Packets: 1},
+		{Timestamp: 101, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.5", Protocol: "UDP", DstPort: 53,
+			Direction: "egress", Size: 80, Packets: 1, Pod: `we"ird\pod`, Labels: map[string]string{"app": "dns"}},
+	}
+	tests := []struct {
+		name      string
+		labels    []string
+		maxSeries uint
+		want      string
+	}{
+		{
+			name:   "reduced labels",
+			labels: []string{"probeIP", "dstPort", "pod"},
+			want: `# HELP wakizashi_bytes_total Total bytes of the traffic.
+# TYPE wakizashi_bytes_total counter
+wakizashi_bytes_total{dst_port="443",pod="",probe_ip="10.0.0.1"} 2000
+wakizashi_bytes_total{dst_port="53",pod="we\"ird\\pod",probe_ip="10.0.0.1"} 80
+# HELP wakizashi_packets_total Total packets of the traffic.
+# TYPE wakizashi_packets_total counter
+wakizashi_packets_total{dst_port="443",pod="",probe_ip="10.0.0.1"} 4
+wakizashi_packets_total{dst_port="53",pod="we\"ird\\pod",probe_ip="10.0.0.1"} 1
+# HELP wakizashi_series Count of traffic series kept.
+# TYPE wakizashi_series gauge
+wakizashi_series 2
+# HELP wakizashi_series_dropped_total Total records dropped for the series limit.
+# TYPE wakizashi_series_dropped_total counter
+wakizashi_series_dropped_total 0
+`,
+		},
+		{
+			name:   "workload label",
+			labels: []string{"pod", "labels.app"},
+			want: `# HELP wakizashi_bytes_total Total bytes of the traffic.
+# TYPE wakizashi_bytes_total counter
+wakizashi_bytes_total{label_app="dns",pod="we\"ird\\pod"} 80
+wakizashi_bytes_total{label_app="",pod=""} 2000
+# HELP wakizashi_packets_total Total packets of the traffic.
+# TYPE wakizashi_packets_total counter
+wakizashi_packets_total{label_app="dns",pod="we\"ird\\pod"} 1
+wakizashi_packets_total{label_app="",pod=""} 4
+# HELP wakizashi_series Count of traffic series kept.
+# TYPE wakizashi_series gauge
+wakizashi_series 2
+# HELP wakizashi_series_dropped_total Total records dropped for the series limit.
+# TYPE wakizashi_series_dropped_total counter
+wakizashi_series_dropped_total 0
+`,
+		},
+		{
+			name:      "series limited",
+			labels:    []string{"srcIP"},
+			maxSeries: 1,
+			want: `# HELP wakizashi_bytes_total Total bytes of the traffic.
+# TYPE wakizashi_bytes_total counter
+wakizashi_bytes_total{src_ip="10.0.0.2"} 1580
+# HELP wakizashi_packets_total Total packets of the traffic.
+# TYPE wakizashi_packets_total counter
+wakizashi_packets_total{src_ip="10.0.0.2"} 4
+# HELP wakizashi_series Count of traffic series kept.
+# TYPE wakizashi_series gauge
+wakizashi_series 1
+# HELP wakizashi_series_dropped_total Total records dropped for the series limit.
+# TYPE wakizashi_series_dropped_total counter
+wakizashi_series_dropped_total 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := createPromClient(config.BackendConfig{PromCfg: config.PrometheusConfig{
+				Addr: "127.0.0.1:0", Labels: tt.labels, MaxSeries: tt.maxSeries}})
+			if err != nil {
+				t.Fatalf("failed to create client, detail: %s", err)
+			}
+			if err := cli.WriteBatch(records); err != nil {
+				t.Fatalf("failed to write, detail: %s", err)
+			}
+			rec := httptest.NewRecorder()
+			cli.(*promClient).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("got content type %s", ct)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromInvalidLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+	}{
+		{"not a tag", []string{"size"}},
+		{"no label name", []string{"labels."}},
+		{"same label name", []string{"labels.app-name", "labels.app_name"}},
+	}
+	for _, tt := range tests {
+		_, err := createPromClient(config.BackendConfig{PromCfg: config.PrometheusConfig{Addr: ":9464", Labels: tt.labels}})
+		if err == nil {
+			t.Errorf("%s: got no error for labels %v", tt.name, tt.labels)
+		}
+	}
+}
@@ -2,34 +2,152 @@ package backend
 
 import (
 	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
 	"BlankZhu/wakizashi/pkg/entity"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
 )
 
+// RedisClient stores the traffic records in time-bucketed hashes, keyed by [database]:[table]:[probeIP]:[bucket]
+// (or [database]:[table]:[tenant]:[probeIP]:[bucket] if the record has a tenant),
+// where bucket is the unix time the bucket starts at. Fields of the hash are the size and packets counters of flows,
+// named [srcIP]|[dstIP]|[protocol]|[srcPort]|[dstPort]|[direction]|size (or packets), and the totals of the probe,
+// named total|size and total|packets. If the probe has identified itself, its identity is kept in the fields named
+// probe|id, probe|hostname, probe|node, probe|pod, probe|namespace and probe|labels (in JSON). Every bucket expires
+// after TTL.
+// A batch is written in a MULTI/EXEC transaction, so it is applied all or nothing, and never counted twice once
+// it fails and is reposted from recovery.
 type RedisClient struct {
+	client     *redis.Client
+	cfg        config.BackendConfig
+	ttl        time.Duration
+	bucketSize time.Duration
+}
+
+func init() {
+	Register(constant.BackendRedis, createRedisClient)
 }
 
-func createRedisClient(cfg config.BackendConfig) *RedisClient {
-	// TODO
-	ret := &RedisClient{}
-	return ret
+func createRedisClient(cfg config.BackendConfig) (DataBackend, error) {
+	rcfg := cfg.RedisCfg
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	var tlsCfg *tls.Config
+	if rcfg.TLS {
+		tlsCfg = &tls.Config{InsecureSkipVerify: rcfg.TLSSkipVerify}
+	}
+
+	ret := &RedisClient{
+		cfg:        cfg,
+		ttl:        time.Duration(rcfg.TTL) * time.Second,
+		bucketSize: time.Duration(rcfg.BucketSize) * time.Second,
+	}
+	if rcfg.TTL == 0 {
+		ret.ttl = constant.RedisDefaultTTL * time.Second
+	}
+	if rcfg.BucketSize == 0 {
+		ret.bucketSize = constant.RedisDefaultBucketSize * time.Second
+	}
+
+	if len(rcfg.SentinelAddrs) != 0 {
+		ret.client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    rcfg.MasterName,
+			SentinelAddrs: rcfg.SentinelAddrs,
+			Password:      rcfg.Password,
+			DB:            rcfg.DB,
+			DialTimeout:   timeout,
+			ReadTimeout:   timeout,
+			WriteTimeout:  timeout,
+			TLSConfig:     tlsCfg,
+		})
+		return ret, nil
+	}
+	ret.client = redis.NewClient(&redis.Options{
+		Addr:         rcfg.Addr,
+		Password:     rcfg.Password,
+		DB:           rcfg.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		TLSConfig:    tlsCfg,
+	})
+	return ret, nil
 }
 
 func (rc *RedisClient) Connect() error {
-	// TODO
-	return nil
+	return rc.client.Ping().Err()
 }
 
 func (rc *RedisClient) Close() error {
-	// TODO
-	return nil
+	return rc.client.Close()
 }
 
 func (rc *RedisClient) Write(record *entity.TrafficRecord) error {
-	// TODO
-	return nil
+	return rc.WriteBatch([]*entity.TrafficRecord{record})
+}
+
+func (rc *RedisClient) WriteBatch(records []*entity.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	pipe := rc.client.TxPipeline()
+	defer pipe.Close()
+	// identities of the probes by keys, nil if not identified
+	keys := make(map[string]*entity.TrafficRecord)
+	for _, record := range records {
+		key := rc.makeKey(record)
+		flow := fmt.Sprintf("%s|%s|%s|%d|%d|%s",
+			record.SrcIP, record.DstIP, record.Protocol, record.SrcPort, record.DstPort, record.Direction)
+		pipe.HIncrBy(key, flow+"|size", int64(record.Size))
+		pipe.HIncrBy(key, flow+"|packets", int64(record.Packets))
+		pipe.HIncrBy(key, "total|size", int64(record.Size))
+		pipe.HIncrBy(key, "total|packets", int64(record.Packets))
+		if record.ProbeID != "" || keys[key] == nil {
+			keys[key] = record
+		}
+	}
+	for key, record := range keys {
+		if record.ProbeID != "" {
+			fields, err := probeFields(record)
+			if err != nil {
+				return err
+			}
+			pipe.HMSet(key, fields)
+		}
+		pipe.Expire(key, rc.ttl)
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// probeFields return the identity of probe in the fields of bucket
+func probeFields(record *entity.TrafficRecord) (map[string]interface{}, error) {
+	labels := record.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode labels, detail: %s", err)
+	}
+	return map[string]interface{}{
+		"probe|id":        record.ProbeID,
+		"probe|hostname":  record.Hostname,
+		"probe|node":      record.Node,
+		"probe|pod":       record.Pod,
+		"probe|namespace": record.Namespace,
+		"probe|labels":    string(b),
+	}, nil
 }
 
-func (rc *RedisClient) WriteBatch(record []*entity.TrafficRecord) error {
-	// TODO
-	return nil
+func (rc *RedisClient) makeKey(record *entity.TrafficRecord) string {
+	bucket := time.Unix(record.Timestamp, 0).Truncate(rc.bucketSize).Unix()
+	if record.Tenant != "" {
+		return fmt.Sprintf("%s:%s:%s:%s:%d", rc.cfg.Database, rc.cfg.Table, record.Tenant, record.ProbeIP, bucket)
+	}
+	return fmt.Sprintf("%s:%s:%s:%d", rc.cfg.Database, rc.cfg.Table, record.ProbeIP, bucket)
 }
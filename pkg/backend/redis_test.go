@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis is a redis stand-in serving the commands written by RedisClient, the hashes are kept in memory.
+// It drops a connection once it has read cutAfter commands from it, if cutAfter is positive.
+type fakeRedis struct {
+	ln       net.Listener
+	cutAfter int
+	mu       sync.Mutex
+	hashes   map[string]map[string]string
+	expiring map[string]bool
+}
+
+func newFakeRedis(t *testing.T, cutAfter int) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, detail: %s", err)
+	}
+	fr := &fakeRedis{
+		ln:       ln,
+		cutAfter: cutAfter,
+		hashes:   make(map[string]map[string]string),
+		expiring: make(map[string]bool),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fr.serve(conn)
+		}
+	}()
+	return fr
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var queued [][]string
+	multi := false
+	for read := 1; ; read++ {
+		args, err := readCommand(r)
+		if err != nil || (fr.cutAfter > 0 && read > fr.cutAfter) {
+			// queued commands of an unfinished transaction are discarded with the connection
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			io.WriteString(conn, "+PONG\r\n")
+		case "MULTI":
+			multi = true
+			io.WriteString(conn, "+OK\r\n")
+		case "EXEC":
+			fmt.Fprintf(conn, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				io.WriteString(conn, fr.apply(cmd))
+			}
+			multi, queued = false, nil
+		default:
+			if multi {
+				queued = append(queued, args)
+				io.WriteString(conn, "+QUEUED\r\n")
+				continue
+			}
+			io.WriteString(conn, fr.apply(args))
+		}
+	}
+}
+
+// apply runs the command, and returns its reply
+func (fr *fakeRedis) apply(args []string) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "HINCRBY":
+		hash := fr.hash(args[1])
+		n, _ := strconv.ParseInt(args[3], 10, 64)
+		prev, _ := strconv.ParseInt(hash[args[2]], 10, 64)
+		hash[args[2]] = strconv.FormatInt(prev+n, 10)
+		return fmt.Sprintf(":%d\r\n", prev+n)
+	case "HMSET":
+		hash := fr.hash(args[1])
+		for i := 2; i+1 < len(args); i += 2 {
+			hash[args[i]] = args[i+1]
+		}
+		return "+OK\r\n"
+	case "EXPIRE":
+		fr.expiring[args[1]] = true
+		return ":1\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// hash returns the hash of key, created if not exists, must be called with mu held
+func (fr *fakeRedis) hash(key string) map[string]string {
+	if fr.hashes[key] == nil {
+		fr.hashes[key] = make(map[string]string)
+	}
+	return fr.hashes[key]
+}
+
+func (fr *fakeRedis) snapshot() map[string]map[string]string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	ret := make(map[string]map[string]string, len(fr.hashes))
+	for k, h := range fr.hashes {
+		ret[k] = make(map[string]string, len(h))
+		for f, v := range h {
+			ret[k][f] = v
+		}
+	}
+	return ret
+}
+
+// readCommand reads a command in RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+func TestRedisWriteBatch(t *testing.T) {
+	records := []*entity.TrafficRecord{
+		{Timestamp: 1614556805, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 100, Protocol: "tcp", DstPort: 80, Direction: "egress", Packets: 2},
+		{Timestamp: 1614556810, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 60, Protocol: "udp", SrcPort: 53, Direction: "ingress", Packets: 1},
+		{Timestamp: 1614556870, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 40, Protocol: "tcp", DstPort: 80, Direction: "egress", Packets: 1, Tenant: "team-a",
+			ProbeID: "id-1", Hostname: "host", Node: "node-1", Pod: "pod-1", Namespace: "ns", Labels: map[string]string{"app": "web"}},
+	}
+
+	tests := []struct {
+		name     string
+		cutAfter int // commands read before the connection is dropped, 0 if never
+		wantErr  bool
+		want     map[string]map[string]string
+	}{
+		{
+			name: "batch applied",
+			want: map[string]map[string]string{
+				"wakizashi:traffic:10.0.0.1:1614556800": {
+					"10.0.0.1|10.0.0.2|tcp|0|80|egress|size":     "100",
+					"10.0.0.1|10.0.0.2|tcp|0|80|egress|packets":  "2",
+					"10.0.0.2|10.0.0.1|udp|53|0|ingress|size":    "60",
+					"10.0.0.2|10.0.0.1|udp|53|0|ingress|packets": "1",
+					"total|size":    "160",
+					"total|packets": "3",
+				},
+				"wakizashi:traffic:team-a:10.0.0.1:1614556860": {
+					"10.0.0.1|10.0.0.2|tcp|0|80|egress|size":    "40",
+					"10.0.0.1|10.0.0.2|tcp|0|80|egress|packets": "1",
+					"total|size":      "40",
+					"total|packets":   "1",
+					"probe|id":        "id-1",
+					"probe|hostname":  "host",
+					"probe|node":      "node-1",
+					"probe|pod":       "pod-1",
+					"probe|namespace": "ns",
+					"probe|labels":    `{"app":"web"}`,
+				},
+			},
+		},
+		{
+			name:     "connection lost in the middle of batch",
+			cutAfter: 6,
+			wantErr:  true,
+			want:     map[string]map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := newFakeRedis(t, tt.cutAfter)
+			defer fr.ln.Close()
+			db, err := createRedisClient(config.BackendConfig{
+				Database: "wakizashi",
+				Table:    "traffic",
+				Timeout:  5,
+				RedisCfg: config.RedisConfig{Addr: fr.ln.Addr().String(), BucketSize: 60},
+			})
+			if err != nil {
+				t.Fatalf("failed to create redis client, detail: %s", err)
+			}
+			defer db.Close()
+
+			err = db.WriteBatch(records)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteBatch returns error %v, want error %t", err, tt.wantErr)
+			}
+			if got := fr.snapshot(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hashes mismatch\ngot:  %v\nwant: %v", got, tt.want)
+			}
+			for key := range tt.want {
+				if !fr.expiring[key] {
+					t.Errorf("key %s is not set to expire", key)
+				}
+			}
+		})
+	}
+}
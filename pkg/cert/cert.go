@@ -0,0 +1,218 @@
+// Package cert describes the TLS credentials between probe and center. The certificate, key and CA files are
+// checked periodically, and reloaded once changed, so rotated certificates take effect without restarts.
+// Example:
+//
+//	r, err := cert.NewReloader(conf.TLS, cert.Server)
+//	if err != nil {
+//	...
+//	}
+//	go r.Watch(ctx)
+//	serv := grpc.NewServer(grpc.Creds(r.TransportCredentials()))
+package cert
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+// Role tells which side of the connection the credentials are used on
+type Role int
+
+const (
+	// Server the credentials are used by center
+	Server Role = iota
+	// Client the credentials are used by probe
+	Client
+)
+
+// fileStamp identifies a version of file by its modification time and size
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader keeps the TLS config built from the files in TLSConfig, and rebuilds it once the files change
+type Reloader struct {
+	cfg    config.TLSConfig
+	role   Role
+	mu     sync.RWMutex
+	tlsCfg *tls.Config
+	stamps map[string]fileStamp
+}
+
+// NewReloader loads the files in TLSConfig, and returns error if they are invalid
+func NewReloader(cfg config.TLSConfig, role Role) (*Reloader, error) {
+	r := &Reloader{
+		cfg:  cfg,
+		role: role,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Watch checks the files every reload interval and reloads them once changed, until ctx is done.
+// If the changed files are invalid, the previous TLS config is kept.
+func (r *Reloader) Watch(ctx context.Context) {
+	interval := time.Duration(r.cfg.ReloadInterval) * time.Second
+	if r.cfg.ReloadInterval == 0 {
+		interval = constant.CertDefaultReloadInterval * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			logrus.Warnf("failed to reload TLS certificates, keep using the previous ones, detail: %s", err)
+			continue
+		}
+		logrus.Infof("TLS certificates reloaded")
+	}
+}
+
+// Reload builds the TLS config from the files
+func (r *Reloader) Reload() error {
+	stamps := r.stampFiles()
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if r.cfg.CertFile != "" || r.cfg.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s and key %s, detail: %s", r.cfg.CertFile, r.cfg.KeyFile, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{pair}
+	} else if r.role == Server {
+		return fmt.Errorf("certificate and key are required by server")
+	}
+
+	var pool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file %s, detail: %s", r.cfg.CAFile, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificate found in CA file %s", r.cfg.CAFile)
+		}
+	}
+
+	switch r.role {
+	case Server:
+		if r.cfg.ClientAuth {
+			if pool == nil {
+				return fmt.Errorf("CA file is required to verify client certificates")
+			}
+			tlsCfg.ClientCAs = pool
+			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case Client:
+		tlsCfg.RootCAs = pool
+		tlsCfg.ServerName = r.cfg.ServerName
+	}
+
+	r.mu.Lock()
+	r.tlsCfg = tlsCfg
+	r.stamps = stamps
+	r.mu.Unlock()
+	return nil
+}
+
+// Config returns the current TLS config, which should not be modified
+func (r *Reloader) Config() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tlsCfg
+}
+
+// TransportCredentials returns the grpc credentials using the current TLS config on every handshake
+func (r *Reloader) TransportCredentials() credentials.TransportCredentials {
+	return &reloadingCreds{reloader: r}
+}
+
+// stampFiles returns the stamps of files, absent files are skipped
+func (r *Reloader) stampFiles() map[string]fileStamp {
+	ret := make(map[string]fileStamp)
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		// stat follows symlinks, so the files mounted from k8s secrets are tracked too
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		ret[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return ret
+}
+
+func (r *Reloader) changed() bool {
+	stamps := r.stampFiles()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(stamps) != len(r.stamps) {
+		return true
+	}
+	for path, s := range stamps {
+		if prev, ok := r.stamps[path]; !ok || !prev.modTime.Equal(s.modTime) || prev.size != s.size {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadingCreds are grpc credentials doing handshakes with the current TLS config of reloader
+type reloadingCreds struct {
+	reloader   *Reloader
+	serverName string
+}
+
+func (c *reloadingCreds) current() credentials.TransportCredentials {
+	tlsCfg := c.reloader.Config().Clone()
+	if c.serverName != "" {
+		tlsCfg.ServerName = c.serverName
+	}
+	return credentials.NewTLS(tlsCfg)
+}
+
+func (c *reloadingCreds) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return c.current().ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c *reloadingCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return c.current().ServerHandshake(rawConn)
+}
+
+func (c *reloadingCreds) Info() credentials.ProtocolInfo {
+	return c.current().Info()
+}
+
+func (c *reloadingCreds) Clone() credentials.TransportCredentials {
+	return &reloadingCreds{reloader: c.reloader, serverName: c.serverName}
+}
+
+func (c *reloadingCreds) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
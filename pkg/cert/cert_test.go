@@ -0,0 +1,241 @@
+package cert
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// testCA signs the certificates of tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wakizashi test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the certificate and key in PEM for the name, usable by both server and client
+func (ca *testCA) issue(t *testing.T, name string, serial int64) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// tempDir creates a temporary directory removed once the test ends
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewReloader(t *testing.T) {
+	dir := tempDir(t)
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "center", 2)
+	_, otherKeyPEM := ca.issue(t, "center", 3)
+	files := map[string][]byte{"ca.pem": ca.pem, "cert.pem": certPEM, "key.pem": keyPEM, "other-key.pem": otherKeyPEM,
+		"garbage.pem": []byte("not a certificate")}
+	for name, data := range files {
+		writeFile(t, filepath.Join(dir, name), data)
+	}
+	path := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name       string
+		role       Role
+		cert       string
+		key        string
+		ca         string
+		clientAuth bool
+		wantErr    bool
+	}{
+		{name: "server", role: Server, cert: "cert.pem", key: "key.pem"},
+		{name: "server with client auth", role: Server, cert: "cert.pem", key: "key.pem", ca: "ca.pem", clientAuth: true},
+		{name: "server without certificate", role: Server, ca: "ca.pem", wantErr: true},
+		{name: "server with client auth without CA", role: Server, cert: "cert.pem", key: "key.pem", clientAuth: true, wantErr: true},
+		{name: "client with system CA", role: Client},
+		{name: "client with CA and certificate", role: Client, cert: "cert.pem", key: "key.pem", ca: "ca.pem"},
+		{name: "mismatched key", role: Server, cert: "cert.pem", key: "other-key.pem", wantErr: true},
+		{name: "missing key", role: Client, cert: "cert.pem", wantErr: true},
+		{name: "invalid CA", role: Client, ca: "garbage.pem", wantErr: true},
+		{name: "absent CA", role: Client, ca: "absent.pem", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReloader(config.TLSConfig{Enabled: true, CertFile: path(tt.cert), KeyFile: path(tt.key),
+				CAFile: path(tt.ca), ClientAuth: tt.clientAuth}, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			cfg := r.Config()
+			if cfg.MinVersion != tls.VersionTLS12 {
+				t.Errorf("got min version %x, want TLS 1.2", cfg.MinVersion)
+			}
+			if tt.clientAuth && (cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil) {
+				t.Errorf("client certificates are not required and verified")
+			}
+			if tt.role == Client && (cfg.RootCAs != nil) != (tt.ca != "") {
+				t.Errorf("got root CAs %v, want CA file %q", cfg.RootCAs, tt.ca)
+			}
+		})
+	}
+}
+
+// handshake does a TLS handshake between the server and client credentials, returns the serial of server certificate
+func handshake(server, client *Reloader) (int64, error) {
+	sconn, cconn := net.Pipe()
+	defer sconn.Close()
+	defer cconn.Close()
+	serr := make(chan error, 1)
+	go func() {
+		_, _, err := server.TransportCredentials().ServerHandshake(sconn)
+		if err != nil {
+			sconn.Close()
+		}
+		serr <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, info, err := client.TransportCredentials().ClientHandshake(ctx, "center:10081", cconn)
+	if err != nil {
+		cconn.Close()
+		<-serr
+		return 0, err
+	}
+	if err := <-serr; err != nil {
+		return 0, err
+	}
+	return info.(credentials.TLSInfo).State.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := tempDir(t)
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "center", 2)
+	clientCertPEM, clientKeyPEM := ca.issue(t, "probe", 3)
+	scfg := config.TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "center.pem"),
+		KeyFile: filepath.Join(dir, "center-key.pem"), CAFile: filepath.Join(dir, "ca.pem"), ClientAuth: true, ReloadInterval: 1}
+	ccfg := config.TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "probe.pem"),
+		KeyFile: filepath.Join(dir, "probe-key.pem"), CAFile: filepath.Join(dir, "ca.pem"), ServerName: "center"}
+	writeFile(t, scfg.CAFile, ca.pem)
+	writeFile(t, scfg.CertFile, certPEM)
+	writeFile(t, scfg.KeyFile, keyPEM)
+	writeFile(t, ccfg.CertFile, clientCertPEM)
+	writeFile(t, ccfg.KeyFile, clientKeyPEM)
+
+	server, err := NewReloader(scfg, Server)
+	if err != nil {
+		t.Fatalf("failed to load server credentials, detail: %s", err)
+	}
+	client, err := NewReloader(ccfg, Client)
+	if err != nil {
+		t.Fatalf("failed to load client credentials, detail: %s", err)
+	}
+	if serial, err := handshake(server, client); err != nil || serial != 2 {
+		t.Fatalf("got serial %d and error %v, want serial 2", serial, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Watch(ctx)
+
+	// an invalid certificate is never loaded, the previous one is kept
+	writeFile(t, scfg.CertFile, []byte("not a certificate"))
+	time.Sleep(1500 * time.Millisecond)
+	if serial, err := handshake(server, client); err != nil || serial != 2 {
+		t.Fatalf("after invalid certificate written, got serial %d and error %v, want serial 2", serial, err)
+	}
+
+	// the rotated certificate is loaded and used by the following handshakes
+	certPEM, keyPEM = ca.issue(t, "center", 4)
+	writeFile(t, scfg.KeyFile, keyPEM)
+	writeFile(t, scfg.CertFile, certPEM)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		serial, err := handshake(server, client)
+		if err == nil && serial == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("after certificate rotated, got serial %d and error %v, want serial 4", serial, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TokenConfig describes a bearer token, given directly or by a file like the one mounted from k8s secret
+type TokenConfig struct {
+	Token     string `yaml:"token,omitempty"`     // the token, ignored if tokenFile is set
+	TokenFile string `yaml:"tokenFile,omitempty"` // file containing the token
+}
+
+// Load return the token, read from TokenFile if set
+func (tc TokenConfig) Load() (string, error) {
+	if tc.TokenFile == "" {
+		return tc.Token, nil
+	}
+	b, err := ioutil.ReadFile(tc.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file %s, detail: %s", tc.TokenFile, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// String hides the token, so it is not logged along with the config
+func (tc TokenConfig) String() string {
+	if tc.TokenFile != "" {
+		return fmt.Sprintf("{TokenFile:%s}", tc.TokenFile)
+	}
+	if tc.Token != "" {
+		return "{Token:******}"
+	}
+	return "{}"
+}
+
+// ProbeAuthConfig describes the bearer token presented by probe to center
+type ProbeAuthConfig struct {
+	TokenConfig `yaml:",inline"`
+	// allow the token to be sent in plaintext if TLS is disabled, for the clusters encrypting the traffic by other means,
+	// like service mesh; if false, TLS is required to present the token
+	AllowInsecureToken bool `yaml:"allowInsecureToken,omitempty"`
+}
+
+// String hides the token, so it is not logged along with the config
+func (pac ProbeAuthConfig) String() string {
+	return fmt.Sprintf("{%s AllowInsecureToken:%t}", pac.TokenConfig, pac.AllowInsecureToken)
+}
+
+// TenantToken is a static token resolving to the tenant
+type TenantToken struct {
+	Tenant      string `yaml:"tenant"` // tenant attached to the records reported with the token
+	TokenConfig `yaml:",inline"`
+}
+
+// AuthConfig describes how center authenticates probes by their bearer tokens
+type AuthConfig struct {
+	Enabled bool          `yaml:"enabled"`          // if true, probes without a valid token are rejected
+	Tokens  []TenantToken `yaml:"tokens,omitempty"` // static tokens with their tenants
+	// secret verifying HMAC-signed tokens, which carry their tenants; if both empty, signed tokens are not accepted
+	HMACSecret     string `yaml:"hmacSecret,omitempty"`
+	HMACSecretFile string `yaml:"hmacSecretFile,omitempty"`
+}
+
+// LoadHMACSecret return the secret verifying signed tokens, read from HMACSecretFile if set
+func (ac AuthConfig) LoadHMACSecret() (string, error) {
+	return TokenConfig{Token: ac.HMACSecret, TokenFile: ac.HMACSecretFile}.Load()
+}
+
+// String hides the tokens and secret, so they are not logged along with the config
+func (ac AuthConfig) String() string {
+	tenants := make([]string, 0, len(ac.Tokens))
+	for _, t := range ac.Tokens {
+		tenants = append(tenants, t.Tenant)
+	}
+	hmac := ac.HMACSecret != "" || ac.HMACSecretFile != ""
+	return fmt.Sprintf("{Enabled:%t Tenants:%v HMAC:%t}", ac.Enabled, tenants, hmac)
+}
@@ -10,13 +10,20 @@ import (
 
 // BackendConfig describes the configuration for data storage backend
 type BackendConfig struct {
-	Type      string       `yaml:"type"`         // backend type
-	Timeout   uint         `yaml:"timeout"`      // connection timeout
-	Database  string       `yaml:"database"`     // database to store traffic records
-	Table     string       `yaml:"table"`        // table(collection in Mongo or measurement in influx) to store traffic records
-	InfluxCfg InfluxConfig `yaml:"influxConfig"` // influxdb connection config section
-	RedisCfg  RedisConfig  `yaml:"redisConfig"`  // redis connection config section
-	MongoCfg  MongoConfig  `yaml:"mongoConfig"`  // mongo connection config section
+	Name       string           `yaml:"name,omitempty"`   // name telling backends apart, if empty, use type
+	Type       string           `yaml:"type"`             // backend type
+	Timeout    uint             `yaml:"timeout"`          // connection timeout
+	Database   string           `yaml:"database"`         // database to store traffic records
+	Table      string           `yaml:"table"`            // table(collection in Mongo or measurement in influx) to store traffic records
+	InfluxCfg  InfluxConfig     `yaml:"influxConfig"`     // influxdb connection config section
+	Influx2Cfg Influx2Config    `yaml:"influx2Config"`    // influxdb 2.x connection config section
+	RedisCfg   RedisConfig      `yaml:"redisConfig"`      // redis connection config section
+	MongoCfg   MongoConfig      `yaml:"mongoConfig"`      // mongo connection config section
+	PgCfg      PostgresConfig   `yaml:"postgresConfig"`   // postgres connection config section
+	ChCfg      ClickHouseConfig `yaml:"clickhouseConfig"` // clickhouse connection config section
+	PromCfg    PrometheusConfig `yaml:"prometheusConfig"` // prometheus exposition config section
+	FileCfg    FileConfig       `yaml:"fileConfig"`       // file sink config section
+	KafkaCfg   KafkaConfig      `yaml:"kafkaConfig"`      // kafka producer config section
 }
 
 func NewBackendConfig() *BackendConfig {
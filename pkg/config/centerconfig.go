@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -15,7 +16,13 @@ type CenterConfig struct {
 	HealthPort    uint16        `yaml:"healthPort"`      // port for health probe
 	RecovDir      string        `yaml:"recoverDir"`      // directory to store the recovery info
 	RecovInterval uint          `yaml:"recoverInterval"` // recovery's repost interval, in second
-	BackendConfig BackendConfig `yaml:"backendConfig"`   // configuration for specific data storage backend
+	BackendConfig BackendConfig `yaml:"backendConfig"`   // configuration for specific data storage backend, deprecated, use BackendConfigs
+	// configurations for data storage backends, every record is written to all of them; if empty, use BackendConfig
+	BackendConfigs []BackendConfig `yaml:"backendConfigs"`
+	// TLS with probes
+	TLS TLSConfig `yaml:"tls,omitempty"`
+	// authentication of probes by bearer tokens, resolving the tenants of records
+	Auth AuthConfig `yaml:"auth,omitempty"`
 }
 
 // LoadConfigFromYAML load config from given path
@@ -28,6 +35,32 @@ func (cc *CenterConfig) LoadConfigFromYAML(path string) error {
 	if err != nil {
 		return err
 	}
+	if len(cc.BackendConfigs) == 0 {
+		cc.BackendConfigs = []BackendConfig{cc.BackendConfig}
+	}
+	names := make(map[string]struct{}, len(cc.BackendConfigs))
+	for i := range cc.BackendConfigs {
+		bc := &cc.BackendConfigs[i]
+		if bc.Name == "" {
+			bc.Name = bc.Type
+		}
+		if bc.Name == "" || strings.ContainsAny(bc.Name, `/\`) || strings.Contains(bc.Name, "..") {
+			return fmt.Errorf("invalid backend name %q, it names the recovery directory of backend, so must not be empty or contain '/', '\\' or '..'", bc.Name)
+		}
+		if _, ok := names[bc.Name]; ok {
+			return fmt.Errorf("duplicated backend name %s, set distinct names for backends of the same type", bc.Name)
+		}
+		names[bc.Name] = struct{}{}
+	}
+	if cc.TLS.Enabled && (cc.TLS.CertFile == "" || cc.TLS.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile are required if TLS is enabled")
+	}
+	if cc.TLS.ClientAuth && cc.TLS.CAFile == "" {
+		return fmt.Errorf("caFile is required to verify probes' certificates if clientAuth is enabled")
+	}
+	if cc.Auth.Enabled && len(cc.Auth.Tokens) == 0 && cc.Auth.HMACSecret == "" && cc.Auth.HMACSecretFile == "" {
+		return fmt.Errorf("no token or HMAC secret given while auth is enabled")
+	}
 	return nil
 }
 
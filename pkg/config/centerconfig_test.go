@@ -0,0 +1,59 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes the yaml to a temporary file, returns its path
+func writeConfig(t *testing.T, yaml string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	p := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(p, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCenterConfigBackendNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []string
+		wantErr bool
+	}{
+		{"name defaults to type", "backendConfigs:\n  - type: influxdb\n  - name: archive\n    type: file\n", []string{"influxdb", "archive"}, false},
+		{"single backendConfig", "backendConfig:\n  type: redis\n", []string{"redis"}, false},
+		{"duplicated", "backendConfigs:\n  - type: file\n  - type: file\n", nil, true},
+		{"slash", "backendConfigs:\n  - name: a/b\n    type: file\n", nil, true},
+		{"backslash", "backendConfigs:\n  - name: a\\\\b\n    type: file\n", nil, true},
+		{"parent", "backendConfigs:\n  - name: ..\n    type: file\n", nil, true},
+		{"empty", "backendConfigs:\n  - timeout: 5\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cc CenterConfig
+			err := cc.LoadConfigFromYAML(writeConfig(t, tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(cc.BackendConfigs) != len(tt.want) {
+				t.Fatalf("got %d backends, want %d", len(cc.BackendConfigs), len(tt.want))
+			}
+			for i, bc := range cc.BackendConfigs {
+				if bc.Name != tt.want[i] {
+					t.Errorf("backend %d: got name %s, want %s", i, bc.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package config
+
+// ClickHouseConfig describes the connection to ClickHouse over its HTTP interface
+type ClickHouseConfig struct {
+	URL      string `yaml:"url"`                // address of HTTP interface, like http://localhost:8123
+	User     string `yaml:"user,omitempty"`     // if empty, use default user
+	Password string `yaml:"password,omitempty"` // password of the user
+	TTLDays  uint   `yaml:"ttlDays,omitempty"`  // days the records are kept, applied when the table is created; if 0, kept forever
+}
@@ -0,0 +1,11 @@
+package config
+
+// FileConfig describes the files the records are appended to, and how they are rotated
+type FileConfig struct {
+	Dir            string `yaml:"dir"`                      // directory of the files, created if not exists
+	Format         string `yaml:"format,omitempty"`         // format of the files: jsonl or csv; if empty, use jsonl
+	MaxSize        uint   `yaml:"maxSize,omitempty"`        // size of a file to be rotated at, in MB; if 0, use 100
+	RotateInterval uint   `yaml:"rotateInterval,omitempty"` // time of a file to be rotated after, in second; if 0, rotate by size only
+	Gzip           bool   `yaml:"gzip,omitempty"`           // compress the rotated files with gzip
+	MaxFiles       uint   `yaml:"maxFiles,omitempty"`       // count of rotated files retained, the oldest are removed; if 0, retain all
+}
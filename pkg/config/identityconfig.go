@@ -0,0 +1,11 @@
+package config
+
+// IdentityConfig describes the identity of probe and the workload it runs alongside, reported to center.
+// Node, pod and namespace are read from env WAKIZASHI_NODE_NAME, WAKIZASHI_POD_NAME and WAKIZASHI_POD_NAMESPACE,
+// which can be set by K8S downward API.
+type IdentityConfig struct {
+	ID         string            `yaml:"id,omitempty"`         // stable ID of probe, if empty, generated once and kept in idFile
+	IDFile     string            `yaml:"idFile,omitempty"`     // file keeping the generated ID, if empty, use [dumpDir]/probe-id
+	Labels     map[string]string `yaml:"labels,omitempty"`     // labels of the workload, like the ones from a config map
+	LabelsFile string            `yaml:"labelsFile,omitempty"` // file of labels in key="value" lines, like the one mounted by K8S downward API
+}
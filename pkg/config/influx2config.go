@@ -0,0 +1,12 @@
+package config
+
+// Influx2Config describes the connection to InfluxDB 2.x, or stores compatible with its write API
+type Influx2Config struct {
+	URL       string   `yaml:"url"`                 // address of influxdb, like http://localhost:8086
+	Org       string   `yaml:"org"`                 // organization the bucket belongs to
+	Bucket    string   `yaml:"bucket,omitempty"`    // bucket to write, if empty, use database
+	Token     string   `yaml:"token"`               // API token with write permission on bucket
+	Precision string   `yaml:"precision,omitempty"` // precision of timestamps: s, ms, us or ns; if empty, use s
+	Gzip      bool     `yaml:"gzip,omitempty"`      // compress the request body with gzip
+	Tags      []string `yaml:"tags,omitempty"`      // attributes of records written as tags, records are summed up by them; if empty, use all but workload labels, added by labels.[name]
+}
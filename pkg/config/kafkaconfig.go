@@ -0,0 +1,13 @@
+package config
+
+// KafkaConfig describes the producer publishing records to a Kafka topic
+type KafkaConfig struct {
+	Brokers      []string `yaml:"brokers"`                // addresses of the brokers, like localhost:9092
+	Topic        string   `yaml:"topic,omitempty"`        // topic to publish, if empty, use table
+	Encoding     string   `yaml:"encoding,omitempty"`     // encoding of messages: json or protobuf; if empty, use json
+	RequiredAcks string   `yaml:"requiredAcks,omitempty"` // acks required from brokers: none, one or all; if empty, use all
+	Compression  string   `yaml:"compression,omitempty"`  // compression of messages: none, gzip, snappy, lz4 or zstd; if empty, use none
+	BatchSize    int      `yaml:"batchSize,omitempty"`    // max messages buffered before sent to a partition, if 0, use 100
+	BatchBytes   int64    `yaml:"batchBytes,omitempty"`   // max bytes of a request sent to a partition, if 0, use 1048576
+	BatchTimeout uint     `yaml:"batchTimeout,omitempty"` // max time to wait for an incomplete batch, in ms; if 0, use 1000
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"BlankZhu/wakizashi/pkg/constant"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -16,7 +17,53 @@ type ProbeConfig struct {
 	NetworkDevs []string `yaml:"networkDevs"`           // network devices' name where the probe work
 	AutoClear   bool     `yaml:"autoClear,omitempty"`   // decide if remove the caputre file or not automatically
 	CapInterval int      `yaml:"capInterval,omitempty"` // interval of rotating dump file, in second; if non-positive, use 1
-	UploadRetry int      `yaml:"uploadRetry,omitempty"` // count of retry to upload traffic status to center
+	UploadRetry int      `yaml:"uploadRetry,omitempty"` // count of consecutive retries to give up reporting to center; if 0, never give up
+	CaptureMode string   `yaml:"captureMode,omitempty"` // file or memory, if empty, use file
+	MaxCache    int      `yaml:"maxCache,omitempty"`    // maximum count of records cached in memory; if non-positive, use 65536
+	// ports regarded as service ports, others are recorded as 0; if empty, use EphemeralPortMin instead
+	ServicePorts []uint16 `yaml:"servicePorts,omitempty"`
+	// ports not less than this are regarded as ephemeral and recorded as 0; if non-positive, use 32768
+	EphemeralPortMin int `yaml:"ephemeralPortMin,omitempty"`
+	// BPF filter expression attached to every network device, traffic with center is always excluded
+	BPFFilter string `yaml:"bpfFilter,omitempty"`
+	// BPF filter expressions by network device's name, overrides BPFFilter
+	DeviceBPFFilters map[string]string `yaml:"deviceBpfFilters,omitempty"`
+	// count of afpacket sockets per network device joined into a fanout group, each with its own decoder;
+	// if less than 2, fanout is disabled
+	CaptureWorkers int `yaml:"captureWorkers,omitempty"`
+	// port of HTTP endpoint serving capture statistics on /stats and reporter state on /reporter; if non-positive, disabled
+	StatsPort int `yaml:"statsPort,omitempty"`
+	// TLS with center
+	TLS TLSConfig `yaml:"tls,omitempty"`
+	// bearer token presented to center, required if center enables auth
+	Auth ProbeAuthConfig `yaml:"auth,omitempty"`
+	// identity of probe and the workload it runs alongside, attached to the records by center
+	Identity IdentityConfig `yaml:"identity,omitempty"`
+	// addresses of centers in [hostname]:[port], balanced in round robin; a hostname may resolve to many centers,
+	// like a headless service; if empty, use CenterAddr
+	CenterAddrs []string `yaml:"centerAddrs,omitempty"`
+	// interval of resolving the center addresses again, in second; if non-positive, use 30
+	CenterResolveInterval int `yaml:"centerResolveInterval,omitempty"`
+	// delay before the first retry to report to center, doubled on every consecutive failure, in second; if non-positive, use 1
+	RetryBackoffMin int `yaml:"retryBackoffMin,omitempty"`
+	// upper bound of delays between retries, in second; if non-positive, use 60
+	RetryBackoffMax int `yaml:"retryBackoffMax,omitempty"`
+	// give up reporting if center is unreachable for this long, in second; if 0, never give up
+	RetryDeadline int `yaml:"retryDeadline,omitempty"`
+}
+
+// NewProbeConfig return the probe config with default values
+func NewProbeConfig() *ProbeConfig {
+	ret := &ProbeConfig{}
+	ret.CapInterval = 1
+	ret.CaptureMode = constant.CaptureModeFile
+	ret.MaxCache = constant.ProbeDefaultMaxCacheSize
+	ret.EphemeralPortMin = constant.DefaultEphemeralPortMin
+	ret.CaptureWorkers = 1
+	ret.CenterResolveInterval = constant.ProbeCenterResolveInterval
+	ret.RetryBackoffMin = constant.ProbeDefaultBackoffMin
+	ret.RetryBackoffMax = constant.ProbeDefaultBackoffMax
+	return ret
 }
 
 // LoadConfigFromYAML load config from given path
@@ -35,9 +82,64 @@ func (pc *ProbeConfig) LoadConfigFromYAML(path string) error {
 	if pc.UploadRetry <= 0 {
 		pc.UploadRetry = 0
 	}
+	if pc.CaptureMode == "" {
+		pc.CaptureMode = constant.CaptureModeFile
+	}
+	if pc.CaptureMode != constant.CaptureModeFile && pc.CaptureMode != constant.CaptureModeMemory {
+		return fmt.Errorf("invalid capture mode %s, use %s or %s", pc.CaptureMode, constant.CaptureModeFile, constant.CaptureModeMemory)
+	}
+	if pc.MaxCache <= 0 {
+		pc.MaxCache = constant.ProbeDefaultMaxCacheSize
+	}
+	if pc.EphemeralPortMin <= 0 {
+		pc.EphemeralPortMin = constant.DefaultEphemeralPortMin
+	}
+	if pc.CaptureWorkers <= 0 {
+		pc.CaptureWorkers = 1
+	}
+	if pc.CenterResolveInterval <= 0 {
+		pc.CenterResolveInterval = constant.ProbeCenterResolveInterval
+	}
+	if pc.RetryBackoffMin <= 0 {
+		pc.RetryBackoffMin = constant.ProbeDefaultBackoffMin
+	}
+	if pc.RetryBackoffMax <= 0 {
+		pc.RetryBackoffMax = constant.ProbeDefaultBackoffMax
+	}
+	if pc.RetryBackoffMax < pc.RetryBackoffMin {
+		return fmt.Errorf("retryBackoffMax %d should not be less than retryBackoffMin %d", pc.RetryBackoffMax, pc.RetryBackoffMin)
+	}
+	if pc.RetryDeadline < 0 {
+		pc.RetryDeadline = 0
+	}
+	if (pc.TLS.CertFile == "") != (pc.TLS.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile should be set together to present a certificate to center")
+	}
+	if (pc.Auth.Token != "" || pc.Auth.TokenFile != "") && !pc.TLS.Enabled && !pc.Auth.AllowInsecureToken {
+		return fmt.Errorf("TLS is required to present bearer token to center, enable TLS or set allowInsecureToken to send it in plaintext")
+	}
 	return nil
 }
 
+// GetBPFFilter return the BPF filter expression of given network device
+func (pc ProbeConfig) GetBPFFilter(dev string) string {
+	if expr, ok := pc.DeviceBPFFilters[dev]; ok {
+		return expr
+	}
+	return pc.BPFFilter
+}
+
+// GetCenterAddrs return the addresses of centers
+func (pc ProbeConfig) GetCenterAddrs() []string {
+	if len(pc.CenterAddrs) != 0 {
+		return pc.CenterAddrs
+	}
+	if pc.CenterAddr == "" {
+		return nil
+	}
+	return []string{pc.CenterAddr}
+}
+
 // ToString return a string representing the config
 func (pc ProbeConfig) ToString() string {
 	ret := fmt.Sprintf("%+v", pc)
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestProbeConfigTokenRequiresTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{"no token", "tls:\n  enabled: false\n", false},
+		{"token over TLS", "tls:\n  enabled: true\nauth:\n  token: secret\n", false},
+		{"token file over TLS", "tls:\n  enabled: true\nauth:\n  tokenFile: /etc/wakizashi/token\n", false},
+		{"token in plaintext", "auth:\n  token: secret\n", true},
+		{"token file in plaintext", "auth:\n  tokenFile: /etc/wakizashi/token\n", true},
+		{"token in plaintext allowed", "auth:\n  token: secret\n  allowInsecureToken: true\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := NewProbeConfig()
+			err := pc.LoadConfigFromYAML(writeConfig(t, tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
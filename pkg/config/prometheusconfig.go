@@ -0,0 +1,10 @@
+package config
+
+// PrometheusConfig describes the endpoint exposing traffic totals as prometheus counters, and how the series are limited
+type PrometheusConfig struct {
+	Addr      string   `yaml:"addr"`                // address to listen on, like :9464
+	Path      string   `yaml:"path,omitempty"`      // path of the metrics, if empty, use /metrics
+	Labels    []string `yaml:"labels,omitempty"`    // attributes of records used as labels, records are summed up by them; if empty, use all but workload labels, added by labels.[name]
+	MaxSeries uint     `yaml:"maxSeries,omitempty"` // max count of series kept, records of new series over it are dropped; if 0, use 10000
+	SeriesTTL uint     `yaml:"seriesTTL,omitempty"` // time a series is kept since it is last updated, in second; if 0, use 600
+}
@@ -1,5 +1,14 @@
 package config
 
+// RedisConfig describes the connection to redis and how the records are bucketed
 type RedisConfig struct {
-	// TODO
+	Addr          string   `yaml:"addr"`                    // address of redis in [host]:[port], ignored if sentinelAddrs is set
+	Password      string   `yaml:"password,omitempty"`      // password of redis
+	DB            int      `yaml:"db,omitempty"`            // redis database number
+	TLS           bool     `yaml:"tls,omitempty"`           // connect to redis over TLS
+	TLSSkipVerify bool     `yaml:"tlsSkipVerify,omitempty"` // skip verifying redis' certificate, for test only
+	MasterName    string   `yaml:"masterName,omitempty"`    // name of master monitored by sentinels
+	SentinelAddrs []string `yaml:"sentinelAddrs,omitempty"` // addresses of sentinels, if set, connect to the master through them
+	TTL           uint     `yaml:"ttl,omitempty"`           // time to live of every bucket, in second; if 0, use 86400
+	BucketSize    uint     `yaml:"bucketSize,omitempty"`    // time span of every bucket, in second; if 0, use 60
 }
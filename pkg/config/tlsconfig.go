@@ -0,0 +1,13 @@
+package config
+
+// TLSConfig describes the TLS between probe and center, the files are reloaded once changed
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`              // enable TLS, if false, communicate in plaintext
+	CertFile   string `yaml:"certFile,omitempty"`   // certificate in PEM, required by center; for probe, presented to center if set
+	KeyFile    string `yaml:"keyFile,omitempty"`    // private key of the certificate in PEM
+	CAFile     string `yaml:"caFile,omitempty"`     // CA certificates in PEM verifying the peer; for probe, if empty, use system's
+	ServerName string `yaml:"serverName,omitempty"` // for probe only, name verified against center's certificate; if empty, use host of center's address
+	ClientAuth bool   `yaml:"clientAuth,omitempty"` // for center only, require probes' certificates and verify them with CAFile
+	// interval of checking the files for changes, in second; if 0, use 60
+	ReloadInterval uint `yaml:"reloadInterval,omitempty"`
+}
@@ -18,6 +18,15 @@ const (
 
 	// RecoveryFlushInterval flush interval of recovery in min
 	RecoveryFlushInterval = 1
+
+	// BackendBreakerThreshold count of consecutive failures before a data backend is regarded unhealthy
+	BackendBreakerThreshold = 3
+	// BackendBreakerCooldown time for an unhealthy data backend to be tried again, in sec
+	BackendBreakerCooldown = 30
+	// BackendReconnectInterval interval to retry connecting a data backend unreachable on startup, in sec
+	BackendReconnectInterval = 10
+	// BackendRepostBatchSize maximum count of records reposted from recovery to data backend in one batch
+	BackendRepostBatchSize = 512
 	// DefaultChanCap default capacity of the channel used by wakizashi
 	DefaultChanCap = 256
 
@@ -33,14 +42,129 @@ const (
 
 	// ProbeTransmitTimeout timeout for probe to transmit data to center, in sec
 	ProbeTransmitTimeout = 60
+	// ProbeConnectTimeout minimum timeout for probe to connect to one center endpoint, in sec
+	ProbeConnectTimeout = 20
+	// ProbeTransmitBatchSize maximum count of records in one batch transmitted to center
+	ProbeTransmitBatchSize = 512
+	// ProbeMaxPendingBatches maximum count of batches waiting for center's acknowledgement
+	ProbeMaxPendingBatches = 1024
+	// ProbeDefaultMaxCacheSize default maximum count of records cached by reporter
+	ProbeDefaultMaxCacheSize = 65536
+	// ProbeAfpacketPollTimeout timeout for probe to wait for packets on afpacket socket, so that changes of center IPs
+	// are applied to the BPF filter even if no packet arrives, in ms
+	ProbeAfpacketPollTimeout = 500
+	// ProbeSocketStatsInterval interval for probe to collect statistics of afpacket socket, in sec
+	ProbeSocketStatsInterval = 5
+	// ProbeSnapLen maximum bytes captured of each packet by probe
+	ProbeSnapLen = 256
+	// ProbeFanoutIDAttempts maximum count of fanout group IDs probe tries, if the IDs are taken by other processes
+	ProbeFanoutIDAttempts = 16
+	// ProbeCenterResolveInterval default interval for probe to resolve the center addresses, in sec
+	ProbeCenterResolveInterval = 30
+	// ProbeCenterLookupTimeout timeout for probe to resolve one center address, in sec
+	ProbeCenterLookupTimeout = 5
+	// ProbeDefaultBackoffMin default delay before probe's first retry to report to center, in sec
+	ProbeDefaultBackoffMin = 1
+	// ProbeDefaultBackoffMax default upper bound of delays between probe's retries to report to center, in sec
+	ProbeDefaultBackoffMax = 60
+	// ProbeBackoffJitter fraction of the delay between retries randomized
+	ProbeBackoffJitter = 0.2
+	// ProbeMinReportInterval minimum interval for probe to transmit the cache to center, in sec
+	ProbeMinReportInterval = 1
+	// ProbeBackoffResetDuration a stream to center lasting this long is regarded as successful and resets the backoff, in sec
+	ProbeBackoffResetDuration = 10
+
+	// ReporterStateConnecting reporter is connecting to center
+	ReporterStateConnecting = "connecting"
+	// ReporterStateConnected reporter is transmitting records to center
+	ReporterStateConnected = "connected"
+	// ReporterStateBackoff reporter is waiting to retry after failing to report to center
+	ReporterStateBackoff = "backoff"
+	// ReporterStateStopped reporter has stopped, or given up reporting to center
+	ReporterStateStopped = "stopped"
+
+	// CaptureModeFile captured traffic is dumped to file, then analyzed by reporter
+	CaptureModeFile = "file"
+	// CaptureModeMemory captured traffic is aggregated in reporter's cache directly
+	CaptureModeMemory = "memory"
+	// SpillFilePrefix prefix of the file holding records spilled from reporter's memory
+	SpillFilePrefix = "spill_"
+	// SpillFileSuffix suffix of the file holding records spilled from reporter's memory
+	SpillFileSuffix = ".json"
+
+	// DefaultEphemeralPortMin ports not less than this are regarded as ephemeral client ports
+	DefaultEphemeralPortMin = 32768
+
+	// ProtocolTCP protocol name of TCP
+	ProtocolTCP = "tcp"
+	// ProtocolUDP protocol name of UDP
+	ProtocolUDP = "udp"
+	// ProtocolSCTP protocol name of SCTP
+	ProtocolSCTP = "sctp"
+	// ProtocolICMP protocol name of ICMPv4
+	ProtocolICMP = "icmp"
+	// ProtocolICMPv6 protocol name of ICMPv6
+	ProtocolICMPv6 = "icmpv6"
+
+	// DirectionIngress traffic received by probe
+	DirectionIngress = "ingress"
+	// DirectionEgress traffic sent by probe
+	DirectionEgress = "egress"
+	// DirectionLocal traffic both sent & received by probe
+	DirectionLocal = "local"
 
 	// BackendInfluxDB backend name of the influxdb
 	BackendInfluxDB = "influxdb"
+	// BackendClickHouse backend name of the clickhouse
+	BackendClickHouse = "clickhouse"
+	// BackendFile backend name of the local files
+	BackendFile = "file"
+	// BackendInfluxDB2 backend name of the influxdb 2.x
+	BackendInfluxDB2 = "influxdb2"
+	// BackendKafka backend name of the kafka
+	BackendKafka = "kafka"
 	// BackendMongoDB backend name of the mongodb
 	BackendMongoDB = "mongodb"
+	// BackendPostgres backend name of the postgresql, or timescaledb
+	BackendPostgres = "postgres"
+	// BackendPrometheus backend name of the prometheus exposition
+	BackendPrometheus = "prometheus"
 	// BackendRedis backend name of the reids
 	BackendRedis = "redis"
 
+	// RedisDefaultTTL default time to live of the buckets in redis, in sec
+	RedisDefaultTTL = 86400
+	// RedisDefaultBucketSize default time span of the buckets in redis, in sec
+	RedisDefaultBucketSize = 60
+
+	// Influx2SumWindow time span of the recent points whose sums are kept by the influxdb2 backend with reduced tags,
+	// records older than it by the latest timestamp are no longer summed up with the written ones, in sec
+	Influx2SumWindow = 600
+
+	// FileDefaultMaxSize default size of a file to be rotated at by the file backend, in MB
+	FileDefaultMaxSize = 100
+
+	// PrometheusDefaultPath default path of the metrics exposed to prometheus
+	PrometheusDefaultPath = "/metrics"
+	// PrometheusDefaultMaxSeries default max count of series exposed to prometheus
+	PrometheusDefaultMaxSeries = 10000
+	// PrometheusDefaultSeriesTTL default time a series exposed to prometheus is kept since last updated, in sec
+	PrometheusDefaultSeriesTTL = 600
+
+	// ProbeIDFileName name of the file keeping the generated probe ID in dump directory
+	ProbeIDFileName = "probe-id"
+	// EnvNodeName env of the K8S node name the probe runs on
+	EnvNodeName = "WAKIZASHI_NODE_NAME"
+	// EnvPodName env of the K8S pod name the probe runs in
+	EnvPodName = "WAKIZASHI_POD_NAME"
+	// EnvPodNamespace env of the K8S pod namespace the probe runs in
+	EnvPodNamespace = "WAKIZASHI_POD_NAMESPACE"
+	// EnvLabelPrefix prefix of envs whose suffixes are label keys and values are label values
+	EnvLabelPrefix = "WAKIZASHI_LABEL_"
+
+	// CertDefaultReloadInterval default interval of checking certificate files for changes, in sec
+	CertDefaultReloadInterval = 60
+
 	// WakizashiDefaultDatabase default database name
 	WakizashiDefaultDatabase = "wakizashi"
 	// WakizashiDefaultTable default table name
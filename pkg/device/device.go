@@ -1,10 +1,30 @@
 package device
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// hardware types of network devices in linux/if_arp.h
+const (
+	arphrdEther    = 1
+	arphrdTunnel   = 768
+	arphrdTunnel6  = 769
+	arphrdLoopback = 772
+	arphrdSit      = 776
+	arphrdNone     = 65534
 )
 
+// sysClassNet where the network devices are described in sysfs
+const sysClassNet = "/sys/class/net"
+
 // GetNetworkDevices fetch network devices by a regex filtering device name
 // todo: regex expression validation
 func GetNetworkDevices(regex string) ([]net.Interface, error) {
@@ -29,3 +49,25 @@ func GetNetworkDevices(regex string) ([]net.Interface, error) {
 func GetAllNetworkDevices() ([]net.Interface, error) {
 	return GetNetworkDevices(".*")
 }
+
+// GetLinkType return the link type of packets captured on the network device by afpacket,
+// return error if it is neither ethernet nor raw IP
+func GetLinkType(name string) (layers.LinkType, error) {
+	b, err := ioutil.ReadFile(path.Join(sysClassNet, name, "type"))
+	if err != nil {
+		return layers.LinkTypeNull, fmt.Errorf("failed to read hardware type of %s, detail: %s", name, err)
+	}
+	hwType, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return layers.LinkTypeNull, fmt.Errorf("invalid hardware type of %s, detail: %s", name, err)
+	}
+
+	switch hwType {
+	case arphrdEther, arphrdLoopback:
+		return layers.LinkTypeEthernet, nil
+	case arphrdTunnel, arphrdTunnel6, arphrdSit, arphrdNone:
+		// without link-layer header, like IPIP tunnel or TUN device
+		return layers.LinkTypeRaw, nil
+	}
+	return layers.LinkTypeNull, fmt.Errorf("unsupported hardware type %d of %s", hwType, name)
+}
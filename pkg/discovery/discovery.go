@@ -0,0 +1,260 @@
+// Package discovery describes how probe finds the centers. The center addresses are resolved periodically,
+// every IP resolved is an endpoint of center, which are balanced in round robin by grpc, and excluded from capturing.
+// Example:
+//
+//	centers := discovery.NewCenters(conf.GetCenterAddrs())
+//	centers.Resolve()
+//	go centers.Watch(ctx, time.Duration(conf.CenterResolveInterval)*time.Second)
+//	conn, err := grpc.DialContext(ctx, centers.DialTarget(), centers.DialOptions()...)
+package discovery
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// scheme of the grpc resolver of centers
+	scheme = "wakizashi"
+	// serviceConfig balances the calls across the ready center endpoints in round robin
+	serviceConfig = `{"loadBalancingConfig":[{"round_robin":{}}]}`
+)
+
+// endpoint an IP of center with the port, and the hostname it is resolved from to verify center's certificate
+type endpoint struct {
+	addr       string
+	serverName string
+}
+
+// Centers keeps the endpoints resolved from the center addresses, and serves them as a grpc resolver.
+// Each address is in [hostname]:[port], a hostname resolving to many IPs (like a headless service) gives many endpoints.
+type Centers struct {
+	version   uint64 // bumped once the endpoints change, accessed atomically
+	Addrs     []string
+	mu        sync.Mutex
+	endpoints map[string][]endpoint // endpoints by the address they are resolved from
+	conns     map[*ccResolver]struct{}
+	resolveCh chan struct{} // signal to resolve before next tick
+}
+
+// NewCenters return the centers of given addresses, which are not resolved until Resolve is called
+func NewCenters(addrs []string) *Centers {
+	return &Centers{
+		Addrs:     addrs,
+		endpoints: make(map[string][]endpoint),
+		conns:     make(map[*ccResolver]struct{}),
+		resolveCh: make(chan struct{}, 1),
+	}
+}
+
+// Resolve looks up the IPs of every center address, return true if the endpoints change.
+// If an address fails to resolve, its previous endpoints are kept.
+func (c *Centers) Resolve() bool {
+	resolved := make(map[string][]endpoint, len(c.Addrs))
+	failed := make([]string, 0)
+	for _, addr := range c.Addrs {
+		eps, err := lookup(addr)
+		if err != nil {
+			logrus.Warnf("failed to resolve center address %s, detail: %s", addr, err)
+			failed = append(failed, addr)
+			continue
+		}
+		resolved[addr] = eps
+	}
+
+	c.mu.Lock()
+	for _, addr := range failed {
+		if eps, ok := c.endpoints[addr]; ok {
+			resolved[addr] = eps
+		}
+	}
+	if sameEndpoints(c.endpoints, resolved) {
+		c.mu.Unlock()
+		return false
+	}
+	c.endpoints = resolved
+	atomic.AddUint64(&c.version, 1)
+	state := c.state()
+	// client conns are updated with mu held, so they never see the endpoints out of order
+	for r := range c.conns {
+		r.update(state)
+	}
+	c.mu.Unlock()
+
+	logrus.Infof("center endpoints resolved: %s", describe(state))
+	return true
+}
+
+// Watch resolves the center addresses every interval, or once grpc asks for it, until ctx is done
+func (c *Centers) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.resolveCh:
+		}
+		c.Resolve()
+	}
+}
+
+// IPs return the IP set of centers, and the version of endpoints it comes from
+func (c *Centers) IPs() (map[string]struct{}, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make(map[string]struct{})
+	for _, eps := range c.endpoints {
+		for _, ep := range eps {
+			host, _, _ := net.SplitHostPort(ep.addr)
+			ret[host] = struct{}{}
+		}
+	}
+	return ret, atomic.LoadUint64(&c.version)
+}
+
+// Version return the version of endpoints, bumped once they change
+func (c *Centers) Version() uint64 {
+	return atomic.LoadUint64(&c.version)
+}
+
+// DialTarget return the target to dial the centers with DialOptions
+func (c *Centers) DialTarget() string {
+	return scheme + ":///centers"
+}
+
+// DialOptions return the options resolving the target by centers, and balancing across them in round robin
+func (c *Centers) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithResolvers(c),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}
+}
+
+// Build implements resolver.Builder, the client conn is updated with the endpoints until it is closed
+func (c *Centers) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r := &ccResolver{centers: c, cc: cc}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[r] = struct{}{}
+	r.update(c.state())
+	return r, nil
+}
+
+// Scheme implements resolver.Builder
+func (c *Centers) Scheme() string {
+	return scheme
+}
+
+// state return the resolver state of endpoints, sorted so that the state is stable, must be called with mu held
+func (c *Centers) state() resolver.State {
+	var addrs []resolver.Address
+	seen := make(map[string]struct{})
+	for _, eps := range c.endpoints {
+		for _, ep := range eps {
+			if _, ok := seen[ep.addr]; ok {
+				continue
+			}
+			seen[ep.addr] = struct{}{}
+			addrs = append(addrs, resolver.Address{Addr: ep.addr, ServerName: ep.serverName})
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Addr < addrs[j].Addr
+	})
+	return resolver.State{Addresses: addrs}
+}
+
+// describe lists the endpoints in state
+func describe(state resolver.State) string {
+	addrs := make([]string, 0, len(state.Addresses))
+	for _, a := range state.Addresses {
+		addrs = append(addrs, a.Addr)
+	}
+	return "[" + strings.Join(addrs, ", ") + "]"
+}
+
+// ccResolver updates a grpc client conn with the endpoints of centers
+type ccResolver struct {
+	centers *Centers
+	cc      resolver.ClientConn
+}
+
+func (r *ccResolver) update(state resolver.State) {
+	if len(state.Addresses) == 0 {
+		r.cc.ReportError(fmt.Errorf("no center endpoint resolved from %v", r.centers.Addrs))
+		return
+	}
+	r.cc.UpdateState(state)
+}
+
+// ResolveNow asks the centers to resolve, without waiting for it
+func (r *ccResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.centers.resolveCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *ccResolver) Close() {
+	r.centers.mu.Lock()
+	delete(r.centers.conns, r)
+	r.centers.mu.Unlock()
+}
+
+// lookup resolves the endpoints of a center address in [hostname]:[port]
+func lookup(addr string) ([]endpoint, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid center address, try this format: [hostname]:[port], detail: %s", err)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []endpoint{{addr: net.JoinHostPort(ip.String(), port), serverName: host}}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), constant.ProbeCenterLookupTimeout*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]endpoint, 0, len(ips))
+	for _, ip := range ips {
+		ret = append(ret, endpoint{addr: net.JoinHostPort(ip.IP.String(), port), serverName: host})
+	}
+	return ret, nil
+}
+
+func sameEndpoints(a, b map[string][]endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for addr, eps := range a {
+		other, ok := b[addr]
+		if !ok || len(other) != len(eps) {
+			return false
+		}
+		set := make(map[endpoint]struct{}, len(eps))
+		for _, ep := range eps {
+			set[ep] = struct{}{}
+		}
+		for _, ep := range other {
+			if _, ok := set[ep]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
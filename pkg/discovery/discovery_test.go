@@ -0,0 +1,173 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeClientConn records the states and errors reported by the resolver of centers
+type fakeClientConn struct {
+	resolver.ClientConn
+	mu     sync.Mutex
+	states []resolver.State
+	errs   []error
+}
+
+func (cc *fakeClientConn) UpdateState(state resolver.State) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.states = append(cc.states, state)
+}
+
+func (cc *fakeClientConn) ReportError(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.errs = append(cc.errs, err)
+}
+
+// last returns the last state, and the count of states and errors reported
+func (cc *fakeClientConn) last() (resolver.State, int, int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.states) == 0 {
+		return resolver.State{}, 0, len(cc.errs)
+	}
+	return cc.states[len(cc.states)-1], len(cc.states), len(cc.errs)
+}
+
+// addrs lists the addresses in state
+func addrs(state resolver.State) []string {
+	ret := make([]string, 0, len(state.Addresses))
+	for _, a := range state.Addresses {
+		ret = append(ret, a.Addr+"@"+a.ServerName)
+	}
+	return ret
+}
+
+func TestCentersResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		addrs       [][]string // center addresses resolved in turn
+		wantChanged []bool
+		wantAddrs   [][]string
+		wantIPs     []map[string]struct{}
+	}{
+		{
+			name:        "IP addresses",
+			addrs:       [][]string{{"10.0.0.2:10081", "10.0.0.1:10081"}, {"10.0.0.1:10081", "10.0.0.2:10081"}},
+			wantChanged: []bool{true, false},
+			wantAddrs:   [][]string{{"10.0.0.1:10081@10.0.0.1", "10.0.0.2:10081@10.0.0.2"}, {"10.0.0.1:10081@10.0.0.1", "10.0.0.2:10081@10.0.0.2"}},
+			wantIPs:     []map[string]struct{}{{"10.0.0.1": {}, "10.0.0.2": {}}, {"10.0.0.1": {}, "10.0.0.2": {}}},
+		},
+		{
+			name:        "addresses updated",
+			addrs:       [][]string{{"10.0.0.1:10081"}, {"10.0.0.3:10081", "[fd00::1]:10081"}},
+			wantChanged: []bool{true, true},
+			wantAddrs:   [][]string{{"10.0.0.1:10081@10.0.0.1"}, {"10.0.0.3:10081@10.0.0.3", "[fd00::1]:10081@fd00::1"}},
+			wantIPs:     []map[string]struct{}{{"10.0.0.1": {}}, {"10.0.0.3": {}, "fd00::1": {}}},
+		},
+		{
+			name:        "same IP on different ports",
+			addrs:       [][]string{{"10.0.0.1:10081", "10.0.0.1:10082"}},
+			wantChanged: []bool{true},
+			wantAddrs:   [][]string{{"10.0.0.1:10081@10.0.0.1", "10.0.0.1:10082@10.0.0.1"}},
+			wantIPs:     []map[string]struct{}{{"10.0.0.1": {}}},
+		},
+		{
+			name:        "invalid address skipped",
+			addrs:       [][]string{{"10.0.0.1:10081", "10.0.0.2"}},
+			wantChanged: []bool{true},
+			wantAddrs:   [][]string{{"10.0.0.1:10081@10.0.0.1"}},
+			wantIPs:     []map[string]struct{}{{"10.0.0.1": {}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCenters(nil)
+			cc := &fakeClientConn{}
+			r, err := c.Build(resolver.Target{}, cc, resolver.BuildOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if _, n, nerr := cc.last(); n != 0 || nerr != 1 {
+				t.Errorf("got %d states and %d errors before resolved, want an error only", n, nerr)
+			}
+
+			for i, a := range tt.addrs {
+				c.Addrs = a
+				prev := c.Version()
+				if changed := c.Resolve(); changed != tt.wantChanged[i] {
+					t.Errorf("resolve %d: got changed %v, want %v", i, changed, tt.wantChanged[i])
+				}
+				if bumped := c.Version() != prev; bumped != tt.wantChanged[i] {
+					t.Errorf("resolve %d: got version bumped %v, want %v", i, bumped, tt.wantChanged[i])
+				}
+				state, _, _ := cc.last()
+				if got := addrs(state); !reflect.DeepEqual(got, tt.wantAddrs[i]) {
+					t.Errorf("resolve %d: got endpoints %v, want %v", i, got, tt.wantAddrs[i])
+				}
+				ips, version := c.IPs()
+				if !reflect.DeepEqual(ips, tt.wantIPs[i]) || version != c.Version() {
+					t.Errorf("resolve %d: got IPs %v of version %d, want %v of version %d", i, ips, version, tt.wantIPs[i], c.Version())
+				}
+			}
+		})
+	}
+}
+
+func TestCentersResolveFailure(t *testing.T) {
+	c := NewCenters([]string{"10.0.0.1:10081", "center.invalid:10081"})
+	// endpoints of an address failed to resolve are kept
+	c.endpoints["center.invalid:10081"] = []endpoint{{addr: "10.0.0.9:10081", serverName: "center.invalid"}}
+	c.Resolve()
+	ips, _ := c.IPs()
+	want := map[string]struct{}{"10.0.0.1": {}, "10.0.0.9": {}}
+	if !reflect.DeepEqual(ips, want) {
+		t.Errorf("got IPs %v, want %v", ips, want)
+	}
+}
+
+func TestCentersWatch(t *testing.T) {
+	c := NewCenters([]string{"10.0.0.1:10081"})
+	c.Resolve()
+	cc := &fakeClientConn{}
+	r, err := c.Build(resolver.Target{}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Watch(ctx, time.Hour)
+
+	// grpc asks to resolve before the next tick, like a connection to center is lost
+	c.Addrs = []string{"10.0.0.2:10081"}
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, _, _ := cc.last()
+		if reflect.DeepEqual(addrs(state), []string{"10.0.0.2:10081@10.0.0.2"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got endpoints %v after resolving now, want 10.0.0.2:10081", addrs(state))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a closed client conn is no longer updated
+	r.Close()
+	_, n, _ := cc.last()
+	c.Addrs = []string{"10.0.0.3:10081"}
+	c.Resolve()
+	if _, after, _ := cc.last(); after != n {
+		t.Errorf("closed client conn updated %d times", after-n)
+	}
+}
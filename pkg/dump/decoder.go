@@ -0,0 +1,197 @@
+package dump
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/types"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/sirupsen/logrus"
+)
+
+// Decoder decodes the packets into raw traffic records, dropping the traffic not related to probe,
+// or between probe and center. It is shared by live capturing and offline replay, not thread-safe
+type Decoder struct {
+	ProbeIPs         map[string]struct{}   // IP set of probe
+	CenterIPs        map[string]struct{}   // IP set of center
+	ServicePorts     []uint16              // ports regarded as service ports, if empty, use EphemeralPortMin instead
+	EphemeralPortMin int                   // ports not less than this are regarded as ephemeral ports
+	LinkType         layers.LinkType       // link type of the packets
+	Counter          *types.CaptureCounter // if set, decode failures and accounted bytes are counted
+	servicePorts     map[uint16]struct{}
+	parsers          map[gopacket.LayerType]*gopacket.DecodingLayerParser
+	decoded          []gopacket.LayerType
+
+	eth     layers.Ethernet
+	sll     layers.LinuxSLL
+	ipv4    layers.IPv4
+	ipv6    layers.IPv6
+	ipv6ext layers.IPv6ExtensionSkipper
+	tcp     layers.TCP
+	udp     layers.UDP
+	icmpv4  layers.ICMPv4
+	icmpv6  layers.ICMPv6
+}
+
+// Init initializes the decoder, return error if the link type is not supported
+func (dec *Decoder) Init() error {
+	dec.servicePorts = make(map[uint16]struct{}, len(dec.ServicePorts))
+	for _, port := range dec.ServicePorts {
+		dec.servicePorts[port] = struct{}{}
+	}
+
+	var firsts []gopacket.LayerType
+	switch dec.LinkType {
+	case layers.LinkTypeEthernet:
+		firsts = []gopacket.LayerType{layers.LayerTypeEthernet}
+	case layers.LinkTypeLinuxSLL:
+		firsts = []gopacket.LayerType{layers.LayerTypeLinuxSLL}
+	case layers.LinkTypeRaw, layers.LinkTypeIPv4, layers.LinkTypeIPv6:
+		firsts = []gopacket.LayerType{layers.LayerTypeIPv4, layers.LayerTypeIPv6}
+	default:
+		return fmt.Errorf("unsupported link type %s", dec.LinkType)
+	}
+
+	dec.parsers = make(map[gopacket.LayerType]*gopacket.DecodingLayerParser, len(firsts))
+	for _, first := range firsts {
+		parser := gopacket.NewDecodingLayerParser(first,
+			&dec.eth, &dec.sll, &dec.ipv4, &dec.ipv6, &dec.ipv6ext, &dec.tcp, &dec.udp, &dec.icmpv4, &dec.icmpv6)
+		parser.IgnoreUnsupported = true
+		dec.parsers[first] = parser
+	}
+	dec.decoded = []gopacket.LayerType{}
+	return nil
+}
+
+// Decode decodes the packet, return nil if the packet is dropped
+func (dec *Decoder) Decode(data []byte, ci gopacket.CaptureInfo) *entity.RawTrafficRecord {
+	parser := dec.parser(data)
+	if parser == nil {
+		dec.countDecodeFailure()
+		return nil
+	}
+	if err := parser.DecodeLayers(data, &dec.decoded); err != nil {
+		logrus.Debugf("failed to decode packet, detail: %s", err)
+		dec.countDecodeFailure()
+	}
+
+	var srcIP, dstIP net.IP
+	var srcPort, dstPort uint16
+	var ipProto layers.IPProtocol
+	var ipPayload []byte
+	protocol := ""
+	for _, lt := range dec.decoded {
+		switch lt {
+		case layers.LayerTypeIPv4:
+			srcIP, dstIP = dec.ipv4.SrcIP, dec.ipv4.DstIP
+			ipProto, ipPayload = dec.ipv4.Protocol, dec.ipv4.Payload
+		case layers.LayerTypeIPv6:
+			srcIP, dstIP = dec.ipv6.SrcIP, dec.ipv6.DstIP
+			ipProto, ipPayload = dec.ipv6.NextHeader, dec.ipv6.Payload
+			// hop-by-hop options are decoded as part of IPv6 layer
+			if dec.ipv6.HopByHop != nil {
+				ipProto = dec.ipv6.HopByHop.NextHeader
+			}
+		case layers.LayerTypeIPv6HopByHop, layers.LayerTypeIPv6Routing,
+			layers.LayerTypeIPv6Fragment, layers.LayerTypeIPv6Destination:
+			ipProto, ipPayload = dec.ipv6ext.NextHeader, dec.ipv6ext.Payload
+		case layers.LayerTypeTCP:
+			protocol = constant.ProtocolTCP
+			srcPort, dstPort = uint16(dec.tcp.SrcPort), uint16(dec.tcp.DstPort)
+		case layers.LayerTypeUDP:
+			protocol = constant.ProtocolUDP
+			srcPort, dstPort = uint16(dec.udp.SrcPort), uint16(dec.udp.DstPort)
+		case layers.LayerTypeICMPv4:
+			protocol = constant.ProtocolICMP
+		case layers.LayerTypeICMPv6:
+			protocol = constant.ProtocolICMPv6
+		}
+	}
+	if srcIP == nil || dstIP == nil {
+		return nil
+	}
+	if protocol == "" {
+		protocol, srcPort, dstPort = undecodedProtocol(ipProto, ipPayload)
+	}
+	src, dst := srcIP.String(), dstIP.String()
+
+	_, pSrcIPCheck := dec.ProbeIPs[src]
+	_, pDstIPCheck := dec.ProbeIPs[dst]
+	if !pSrcIPCheck && !pDstIPCheck {
+		return nil
+	}
+	_, cSrcIPCheck := dec.CenterIPs[src]
+	_, cDstIPCheck := dec.CenterIPs[dst]
+	if cDstIPCheck || cSrcIPCheck {
+		return nil
+	}
+
+	if dec.Counter != nil {
+		dec.Counter.AddBytes(uint64(ci.Length))
+	}
+	return &entity.RawTrafficRecord{
+		SrcIP:    src,
+		DstIP:    dst,
+		Size:     uint64(ci.Length),
+		Protocol: protocol,
+		SrcPort:  dec.servicePort(srcPort),
+		DstPort:  dec.servicePort(dstPort),
+	}
+}
+
+func (dec *Decoder) countDecodeFailure() {
+	if dec.Counter != nil {
+		dec.Counter.AddDecodeFailures(1)
+	}
+}
+
+// parser picks the parser by link type, raw IP packets are told apart by IP version
+func (dec *Decoder) parser(data []byte) *gopacket.DecodingLayerParser {
+	if len(dec.parsers) == 1 {
+		for _, parser := range dec.parsers {
+			return parser
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return dec.parsers[layers.LayerTypeIPv4]
+	case 6:
+		return dec.parsers[layers.LayerTypeIPv6]
+	}
+	return nil
+}
+
+// servicePort return the port if it is regarded as a service port, otherwise 0,
+// so that ephemeral client ports won't explode the cardinality of records
+func (dec *Decoder) servicePort(port uint16) uint16 {
+	if len(dec.servicePorts) != 0 {
+		if _, ok := dec.servicePorts[port]; ok {
+			return port
+		}
+		return 0
+	}
+	if int(port) >= dec.EphemeralPortMin {
+		return 0
+	}
+	return port
+}
+
+// undecodedProtocol names the layer-4 protocol not decoded by parser,
+// ports of SCTP are extracted from its common header directly
+func undecodedProtocol(ipProto layers.IPProtocol, payload []byte) (string, uint16, uint16) {
+	if ipProto == layers.IPProtocolSCTP {
+		if len(payload) < 4 {
+			return constant.ProtocolSCTP, 0, 0
+		}
+		return constant.ProtocolSCTP, binary.BigEndian.Uint16(payload[0:2]), binary.BigEndian.Uint16(payload[2:4])
+	}
+	return strings.ToLower(ipProto.String()), 0, 0
+}
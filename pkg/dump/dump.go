@@ -2,103 +2,210 @@ package dump
 
 import (
 	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/device"
+	"BlankZhu/wakizashi/pkg/discovery"
 	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/filter"
+	"BlankZhu/wakizashi/pkg/types"
 	"BlankZhu/wakizashi/pkg/util"
 	"bufio"
 	"fmt"
+	"math/rand"
 	"net"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/google/gopacket"
 	"github.com/google/gopacket/afpacket"
 	"github.com/google/gopacket/layers"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/bpf"
 )
 
 // Dumper dumps the traffic of a specified network interface device
 type Dumper struct {
-	Iface          *net.Interface
-	SnapLen        uint32
-	RotateInterval time.Duration // rotate captured file
-	RepAddr        string        // address of the center
-	DumpDir        string
-	FileCh         chan<- string
-	rawDataCh      chan *entity.RawTrafficRecord
+	Iface            *net.Interface
+	SnapLen          uint32
+	RotateInterval   time.Duration      // rotate captured file
+	Centers          *discovery.Centers // centers whose traffic is excluded, refreshed once they are resolved again
+	DumpDir          string
+	FileCh           chan<- string
+	RecordCh         chan<- *entity.RawTrafficRecord // if set, records are sent to reporter directly instead of dumping to file
+	ServicePorts     []uint16                        // ports regarded as service ports, if empty, use EphemeralPortMin instead
+	EphemeralPortMin int                             // ports not less than this are regarded as ephemeral ports
+	BPFFilter        string                          // filter expression attached to the afpacket handle
+	Counter          *types.CaptureCounter           // counts the capture statistics, created by Init if not set
+	Workers          int                             // count of afpacket sockets joined into a fanout group, if less than 2, fanout is disabled
+	rawDataCh        chan *entity.RawTrafficRecord
+	linkType         layers.LinkType
 }
 
 // Init initializes the dumper
 func (d *Dumper) Init() {
 	d.rawDataCh = make(chan *entity.RawTrafficRecord, constant.DefaultChanCap)
+	if d.Counter == nil {
+		d.Counter = types.NewCaptureCounter(d.Iface.Name)
+	}
 }
 
-// Start starts the dumping process, generating the afpacket file
+// Start starts the dumping process, generating the afpacket file,
+// or sending the records to RecordCh if it is set
 func (d *Dumper) Start() {
+	if d.RecordCh != nil {
+		d.dump(d.RecordCh)
+		return
+	}
 	go d.genFile()
-	d.dump()
+	d.dump(d.rawDataCh)
 }
 
-func (d *Dumper) dump() {
-	probeIPs := util.GetIPSetFromNetworkInterface(d.Iface)
-	centerIPs := make(map[string]struct{})
-	splited := strings.Split(d.RepAddr, ":")
-	if len(splited) != 2 {
-		logrus.Warnf("might using invalid center address %s, try this format: [hostname]:[port]", d.RepAddr)
+func (d *Dumper) dump(out chan<- *entity.RawTrafficRecord) {
+	linkType, err := device.GetLinkType(d.Iface.Name)
+	if err != nil {
+		logrus.Errorf("failed to capture on %s, detail: %s", d.Iface.Name, err)
+		return
 	}
-	cips, err := net.LookupIP(splited[0])
+	d.linkType = linkType
+
+	centerIPs, version := d.Centers.IPs()
+	prog, err := d.compileFilter(centerIPs)
 	if err != nil {
-		logrus.Warnf("failed to lookup IP for %s, detail: %s", splited[0], err)
-	} else {
-		for _, v := range cips {
-			centerIPs[v.String()] = struct{}{}
-		}
+		logrus.Errorf("failed to compile BPF filter for %s, detail: %s", d.Iface.Name, err)
+		return
 	}
 
-	handle, err := d.getAfpacketHandle()
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	handles, err := d.openHandles(workers, prog)
 	if err != nil {
-		logrus.Errorf("failed to create afpacket handle, detail: %s", err)
+		logrus.Errorf("failed to capture on %s, detail: %s", d.Iface.Name, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, handle := range handles {
+		handle := handle
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer handle.Close()
+			d.capture(out, handle, centerIPs, version)
+		}()
+	}
+	wg.Wait()
+}
+
+// openHandles opens n afpacket sockets with the BPF filter attached, if n is more than 1,
+// they are joined into a new fanout group, so that packets of the same flow are always captured by the same socket
+func (d *Dumper) openHandles(n int, prog []bpf.RawInstruction) ([]*afpacket.TPacket, error) {
+	handles := make([]*afpacket.TPacket, 0, n)
+	closeAll := func() {
+		for _, handle := range handles {
+			handle.Close()
+		}
+	}
+
+	var fanoutID uint16
+	for i := 0; i < n; i++ {
+		handle, err := d.getAfpacketHandle()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to create afpacket handle, detail: %s", err)
+		}
+		handles = append(handles, handle)
+		if prog != nil {
+			if err = handle.SetBPF(prog); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to set BPF filter, detail: %s", err)
+			}
+		}
+		if n < 2 {
+			break
+		}
+		if i == 0 {
+			fanoutID, err = joinNewFanout(handle)
+		} else {
+			err = handle.SetFanout(afpacket.FanoutHashWithDefrag, fanoutID)
+		}
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to join fanout group %d, detail: %s", fanoutID, err)
+		}
+	}
+	if n > 1 {
+		logrus.Infof("capturing on %s with %d workers in fanout group %d", d.Iface.Name, n, fanoutID)
+	}
+	return handles, nil
+}
+
+// capture reads and decodes the packets from an afpacket socket, the center IPs are refreshed once changed,
+// checked at least every poll timeout while no packet arrives
+func (d *Dumper) capture(out chan<- *entity.RawTrafficRecord, handle *afpacket.TPacket, centerIPs map[string]struct{}, version uint64) {
+	dec := &Decoder{
+		ProbeIPs:         util.GetIPSetFromNetworkInterface(d.Iface),
+		CenterIPs:        centerIPs,
+		ServicePorts:     d.ServicePorts,
+		EphemeralPortMin: d.EphemeralPortMin,
+		LinkType:         d.linkType,
+		Counter:          d.Counter,
+	}
+	if err := dec.Init(); err != nil {
+		logrus.Errorf("failed to create decoder, detail: %s", err)
 		return
 	}
-	defer handle.Close()
 
-	var eth layers.Ethernet
-	var ipv4 layers.IPv4
-	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &ipv4)
-	decoded := []gopacket.LayerType{}
+	done := make(chan struct{})
+	defer close(done)
+	go d.collectSocketStats(handle, done)
 
 	for {
+		if d.Centers.Version() != version {
+			version = d.refreshCenters(handle, dec)
+		}
 		data, ci, err := handle.ZeroCopyReadPacketData()
+		if err == afpacket.ErrTimeout {
+			continue
+		}
 		if err != nil {
 			logrus.Warnf("failed to zero copy afpacket data, detail: %s", err)
+			d.Counter.AddReadErrors(1)
+			continue
 		}
-		parser.DecodeLayers(data, &decoded)
-		for _, lt := range decoded {
-			switch lt {
-			case layers.LayerTypeIPv4:
-				_, pSrcIPCheck := probeIPs[ipv4.SrcIP.String()]
-				_, pDstIPCheck := probeIPs[ipv4.SrcIP.String()]
-				if !pSrcIPCheck && !pDstIPCheck {
-					continue
-				}
-				_, cSrcIPCheck := centerIPs[ipv4.SrcIP.String()]
-				_, cDstIPCheck := centerIPs[ipv4.SrcIP.String()]
-				if cDstIPCheck || cSrcIPCheck {
-					continue
-				}
-
-				rd := &entity.RawTrafficRecord{
-					SrcIP: ipv4.SrcIP.String(),
-					DstIP: ipv4.DstIP.String(),
-					Size:  uint64(ci.Length),
-				}
-				d.rawDataCh <- rd
-			}
+		d.Counter.AddPackets(1)
+		if rd := dec.Decode(data, ci); rd != nil {
+			out <- rd
 		}
 	}
 }
 
+// nextFanoutID the last fanout group ID allocated in this process, accessed atomically.
+// It is seeded randomly, so that the probes sharing a network namespace are unlikely to pick the same IDs.
+var nextFanoutID = uint32(rand.New(rand.NewSource(time.Now().UnixNano() + int64(os.Getpid()))).Intn(1 << 16))
+
+// joinNewFanout joins the handle into a fanout group of ID never allocated in this process, return the ID.
+// If the ID is taken by another process on other network device or in other fanout mode, try the next one.
+func joinNewFanout(handle *afpacket.TPacket) (uint16, error) {
+	for i := 0; i < constant.ProbeFanoutIDAttempts; i++ {
+		id := uint16(atomic.AddUint32(&nextFanoutID, 1))
+		err := handle.SetFanout(afpacket.FanoutHashWithDefrag, id)
+		if err == nil {
+			return id, nil
+		}
+		if err != syscall.EINVAL {
+			return id, err
+		}
+		logrus.Debugf("fanout group %d is taken, try another one", id)
+	}
+	return 0, fmt.Errorf("no fanout group available after %d attempts", constant.ProbeFanoutIDAttempts)
+}
+
 func (d *Dumper) genFile() {
 	ticker := time.NewTicker(d.RotateInterval)
 	defer ticker.Stop()
@@ -120,7 +227,7 @@ func (d *Dumper) genFile() {
 
 			err = fp.Close()
 			if err != nil {
-				logrus.Errorf("failed to close dump file %s, detail: %s")
+				logrus.Errorf("failed to close dump file %s, detail: %s", fp.Name(), err)
 				continue
 			}
 
@@ -164,7 +271,7 @@ func (d *Dumper) getAfpacketHandle() (*afpacket.TPacket, error) {
 		afpacket.OptBlockSize(szBlock),
 		afpacket.OptNumBlocks(numBlocks),
 		afpacket.OptAddVLANHeader(false),
-		afpacket.OptPollTimeout(-time.Millisecond*10), // pcap.BlockForever
+		afpacket.OptPollTimeout(constant.ProbeAfpacketPollTimeout*time.Millisecond),
 		afpacket.SocketRaw,
 		afpacket.TPacketVersion3)
 	if err != nil {
@@ -173,6 +280,84 @@ func (d *Dumper) getAfpacketHandle() (*afpacket.TPacket, error) {
 	return ret, nil
 }
 
+// collectSocketStats counts the packets dropped by kernel periodically, until done is closed
+func (d *Dumper) collectSocketStats(handle *afpacket.TPacket, done <-chan struct{}) {
+	ticker := time.NewTicker(constant.ProbeSocketStatsInterval * time.Second)
+	defer ticker.Stop()
+
+	var lastDrops uint64
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// counters of socket are accumulated by afpacket
+			_, stats, err := handle.SocketStats()
+			if err != nil {
+				logrus.Warnf("failed to get afpacket socket statistics on %s, detail: %s", d.Iface.Name, err)
+				continue
+			}
+			drops := uint64(stats.Drops())
+			if drops > lastDrops {
+				logrus.Warnf("%d packets dropped by kernel on %s", drops-lastDrops, d.Iface.Name)
+				d.Counter.AddDrops(drops - lastDrops)
+			}
+			lastDrops = drops
+		}
+	}
+}
+
+// refreshCenters updates the center IP set of decoder and the BPF filter on handle, return the version of center IPs.
+// If the new filter fails to compile or attach, the previous one is kept.
+func (d *Dumper) refreshCenters(handle *afpacket.TPacket, dec *Decoder) uint64 {
+	centerIPs, version := d.Centers.IPs()
+	dec.CenterIPs = centerIPs
+	prog, err := d.compileFilter(centerIPs)
+	if err != nil {
+		logrus.Errorf("failed to compile BPF filter for %s, detail: %s", d.Iface.Name, err)
+		return version
+	}
+	if prog == nil {
+		// no filter needed any more, replace the previous one with accepting every packet
+		prog, _ = bpf.Assemble([]bpf.Instruction{bpf.RetConstant{Val: d.SnapLen}})
+	}
+	if err = handle.SetBPF(prog); err != nil {
+		logrus.Errorf("failed to set BPF filter on %s, detail: %s", d.Iface.Name, err)
+	}
+	return version
+}
+
+// compileFilter compiles the BPF filter excluding the traffic with center, return nil if no filter is needed
+func (d *Dumper) compileFilter(centerIPs map[string]struct{}) ([]bpf.RawInstruction, error) {
+	expr := BuildFilter(d.BPFFilter, centerIPs)
+	if expr == "" {
+		return nil, nil
+	}
+	prog, err := filter.Compile(expr, d.linkType, int(d.SnapLen))
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("BPF filter on %s: %s", d.Iface.Name, expr)
+	return prog, nil
+}
+
+// BuildFilter combines the filter expression with the exclusion of center's IP set
+func BuildFilter(expr string, centerIPs map[string]struct{}) string {
+	var hosts []string
+	for ip := range centerIPs {
+		hosts = append(hosts, "host "+ip)
+	}
+	if len(hosts) == 0 {
+		return expr
+	}
+	sort.Strings(hosts)
+	exclusion := fmt.Sprintf("not (%s)", strings.Join(hosts, " or "))
+	if strings.TrimSpace(expr) == "" {
+		return exclusion
+	}
+	return fmt.Sprintf("(%s) and %s", expr, exclusion)
+}
+
 func afpacketComputeSize(targetSizeMb int, snaplen int, pageSize int) (
 	frameSize int, blockSize int, numBlocks int, err error) {
 
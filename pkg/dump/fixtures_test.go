@@ -0,0 +1,267 @@
+package dump
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var update = flag.Bool("update", false, "regenerate the pcap fixtures in testdata")
+
+// addresses used by the fixtures, the probe talks with the peers and the center, others are unrelated
+var (
+	probeIP4  = net.ParseIP("10.0.0.1").To4()
+	probe2IP4 = net.ParseIP("10.0.0.3").To4()
+	peerIP4   = net.ParseIP("10.0.0.2").To4()
+	centerIP4 = net.ParseIP("10.0.0.100").To4()
+	otherIP4  = net.ParseIP("192.168.1.1").To4()
+	probeIP6  = net.ParseIP("fd00::1")
+	peerIP6   = net.ParseIP("fd00::2")
+	centerIP6 = net.ParseIP("fd00::100")
+
+	probeMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	peerMAC  = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+
+	fixtureEpoch = time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// fixturePacket is a packet in fixture, captured at offset from fixtureEpoch
+type fixturePacket struct {
+	offset time.Duration
+	data   []byte
+}
+
+// fixture is a capture file in testdata
+type fixture struct {
+	name     string
+	linkType layers.LinkType
+	ng       bool // written in pcapng instead of pcap
+	packets  []fixturePacket
+}
+
+var fixtures = []fixture{
+	{
+		name:     "ipv4.pcap",
+		linkType: layers.LinkTypeEthernet,
+		packets: []fixturePacket{
+			// two packets of the same flow in the first second
+			{0, ether(layers.EthernetTypeIPv4, ipv4(peerIP4, probeIP4, layers.IPProtocolTCP), tcp(40000, 80, 0))},
+			{500 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(peerIP4, probeIP4, layers.IPProtocolTCP), tcp(40000, 80, 100))},
+			{600 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(probeIP4, peerIP4, layers.IPProtocolTCP), tcp(80, 40000, 1000))},
+			{700 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(probeIP4, peerIP4, layers.IPProtocolUDP), udp(50000, 53, 20))},
+			{800 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(probeIP4, peerIP4, layers.IPProtocolICMPv4), icmp4())},
+			// traffic with center and unrelated traffic are dropped
+			{900 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(probeIP4, centerIP4, layers.IPProtocolTCP), tcp(40001, 10080, 10))},
+			{900 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(centerIP4, probeIP4, layers.IPProtocolTCP), tcp(10080, 40001, 10))},
+			{900 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(otherIP4, peerIP4, layers.IPProtocolUDP), udp(50000, 53, 20))},
+			{900 * time.Millisecond, ether(layers.EthernetTypeARP, arp())},
+			// the next second
+			{1200 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(peerIP4, probeIP4, layers.IPProtocolSCTP), sctp(5000, 3868))},
+			{1300 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(probeIP4, probe2IP4, layers.IPProtocolTCP), tcp(40002, 8080, 0))},
+			{1400 * time.Millisecond, ether(layers.EthernetTypeIPv4, ipv4(peerIP4, probeIP4, layers.IPProtocolTCP), tcp(40000, 80, 0))},
+		},
+	},
+	{
+		name:     "ipv6.pcapng",
+		linkType: layers.LinkTypeEthernet,
+		ng:       true,
+		packets: []fixturePacket{
+			{0, ether(layers.EthernetTypeIPv6, ipv6(peerIP6, probeIP6, layers.IPProtocolTCP), tcp(40000, 443, 0))},
+			// extension headers between IPv6 and layer-4 header
+			{100 * time.Millisecond, ether(layers.EthernetTypeIPv6, ipv6(probeIP6, peerIP6, layers.IPProtocolIPv6HopByHop),
+				ext(layers.IPProtocolUDP), udp(50000, 53, 20))},
+			{200 * time.Millisecond, ether(layers.EthernetTypeIPv6, ipv6(probeIP6, peerIP6, layers.IPProtocolIPv6HopByHop),
+				ext(layers.IPProtocolIPv6Destination), ext(layers.IPProtocolTCP), tcp(443, 40000, 100))},
+			{300 * time.Millisecond, ether(layers.EthernetTypeIPv6, ipv6(peerIP6, probeIP6, layers.IPProtocolIPv6Fragment),
+				fragment(layers.IPProtocolUDP), udp(40000, 514, 100))},
+			{400 * time.Millisecond, ether(layers.EthernetTypeIPv6, ipv6(probeIP6, peerIP6, layers.IPProtocolICMPv6), icmp6())},
+			{500 * time.Millisecond, ether(layers.EthernetTypeIPv6, ipv6(probeIP6, centerIP6, layers.IPProtocolTCP), tcp(40001, 10080, 10))},
+		},
+	},
+	{
+		// like the packets captured on an IPIP tunnel device, without link-layer header
+		name:     "raw.pcap",
+		linkType: layers.LinkTypeRaw,
+		packets: []fixturePacket{
+			{0, serialize(ipv4(peerIP4, probeIP4, layers.IPProtocolTCP), tcp(40000, 80, 0))},
+			{100 * time.Millisecond, serialize(ipv6(probeIP6, peerIP6, layers.IPProtocolUDP), udp(50000, 53, 20))},
+			{200 * time.Millisecond, serialize(ipv4(probeIP4, centerIP4, layers.IPProtocolTCP), tcp(40001, 10080, 10))},
+		},
+	},
+}
+
+func serialize(ls ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func ether(t layers.EthernetType, ls ...gopacket.SerializableLayer) []byte {
+	eth := &layers.Ethernet{SrcMAC: peerMAC, DstMAC: probeMAC, EthernetType: t}
+	return serialize(append([]gopacket.SerializableLayer{eth}, ls...)...)
+}
+
+func ipv4(src, dst net.IP, proto layers.IPProtocol) gopacket.SerializableLayer {
+	return &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: proto, SrcIP: src, DstIP: dst}
+}
+
+func ipv6(src, dst net.IP, next layers.IPProtocol) gopacket.SerializableLayer {
+	return &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: next, SrcIP: src, DstIP: dst}
+}
+
+// ext is an IPv6 hop-by-hop or destination options header padded by PadN
+func ext(next layers.IPProtocol) gopacket.SerializableLayer {
+	return gopacket.Payload{byte(next), 0, 1, 4, 0, 0, 0, 0}
+}
+
+// fragment is the IPv6 fragment header of a first fragment
+func fragment(next layers.IPProtocol) gopacket.SerializableLayer {
+	return gopacket.Payload{byte(next), 0, 0, 1, 0, 0, 0, 42}
+}
+
+func tcp(src, dst uint16, payload int) gopacket.SerializableLayer {
+	return &tcpWithPayload{
+		tcp:     layers.TCP{SrcPort: layers.TCPPort(src), DstPort: layers.TCPPort(dst), ACK: true, Window: 1024},
+		payload: payload,
+	}
+}
+
+func udp(src, dst uint16, payload int) gopacket.SerializableLayer {
+	return &udpWithPayload{
+		udp:     layers.UDP{SrcPort: layers.UDPPort(src), DstPort: layers.UDPPort(dst)},
+		payload: payload,
+	}
+}
+
+// sctp is the SCTP common header followed by nothing, which is enough for its ports
+func sctp(src, dst uint16) gopacket.SerializableLayer {
+	return gopacket.Payload{byte(src >> 8), byte(src), byte(dst >> 8), byte(dst), 0, 0, 0, 0, 0, 0, 0, 0}
+}
+
+func icmp4() gopacket.SerializableLayer {
+	return &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1}
+}
+
+func icmp6() gopacket.SerializableLayer {
+	return gopacket.Payload{byte(layers.ICMPv6TypeEchoRequest), 0, 0, 0, 0, 1, 0, 1}
+}
+
+func arp() gopacket.SerializableLayer {
+	return &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   peerMAC,
+		SourceProtAddress: peerIP4,
+		DstHwAddress:      make([]byte, 6),
+		DstProtAddress:    probeIP4,
+	}
+}
+
+// tcpWithPayload serializes the TCP header together with zeroed payload, so that FixLengths covers both
+type tcpWithPayload struct {
+	tcp     layers.TCP
+	payload int
+}
+
+func (l *tcpWithPayload) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if _, err := b.PrependBytes(l.payload); err != nil {
+		return err
+	}
+	return l.tcp.SerializeTo(b, opts)
+}
+
+func (l *tcpWithPayload) LayerType() gopacket.LayerType {
+	return layers.LayerTypeTCP
+}
+
+// udpWithPayload serializes the UDP header together with zeroed payload, so that FixLengths covers both
+type udpWithPayload struct {
+	udp     layers.UDP
+	payload int
+}
+
+func (l *udpWithPayload) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if _, err := b.PrependBytes(l.payload); err != nil {
+		return err
+	}
+	return l.udp.SerializeTo(b, opts)
+}
+
+func (l *udpWithPayload) LayerType() gopacket.LayerType {
+	return layers.LayerTypeUDP
+}
+
+// encode encodes the fixture into a pcap or pcapng file
+func (f fixture) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	write := func(ci gopacket.CaptureInfo, data []byte) error { return nil }
+	flush := func() error { return nil }
+	if f.ng {
+		intf := pcapgo.NgInterface{Name: "eth0", OS: "linux", LinkType: f.linkType, TimestampResolution: 9}
+		w, err := pcapgo.NewNgWriterInterface(&buf, intf, pcapgo.NgWriterOptions{
+			SectionInfo: pcapgo.NgSectionInfo{Application: "wakizashi tests"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		write, flush = w.WritePacket, w.Flush
+	} else {
+		w := pcapgo.NewWriterNanos(&buf)
+		if err := w.WriteFileHeader(65535, f.linkType); err != nil {
+			return nil, err
+		}
+		write = w.WritePacket
+	}
+	for _, p := range f.packets {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     fixtureEpoch.Add(p.offset),
+			CaptureLength: len(p.data),
+			Length:        len(p.data),
+		}
+		if err := write(ci, p.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TestFixtures checks the fixtures in testdata are generated from the packets above,
+// run `go test ./pkg/dump -run TestFixtures -update` to regenerate them after changing the packets
+func TestFixtures(t *testing.T) {
+	for _, f := range fixtures {
+		want, err := f.encode()
+		if err != nil {
+			t.Fatalf("failed to encode %s: %s", f.name, err)
+		}
+		filename := path.Join("testdata", f.name)
+		if *update {
+			if err := ioutil.WriteFile(filename, want, 0644); err != nil {
+				t.Fatalf("failed to write %s: %s", filename, err)
+			}
+			continue
+		}
+		got, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", filename, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date, regenerate it with -update", filename)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package dump
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/sirupsen/logrus"
+)
+
+// pcapngMagic magic number of the section header block of pcapng
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// packetReader reads packets from pcap or pcapng file
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// Replay feeds the packets in pcap or pcapng file through dec, just like dumping on a network interface,
+// the records decoded are handed to fn along with their capture time
+func Replay(filename string, dec *Decoder, fn func(*entity.RawTrafficRecord, time.Time)) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pr, err := newPacketReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	dec.LinkType = pr.LinkType()
+	if err := dec.Init(); err != nil {
+		return err
+	}
+
+	cnt := 0
+	for {
+		data, ci, err := pr.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		cnt++
+		if rd := dec.Decode(data, ci); rd != nil {
+			fn(rd, ci.Timestamp)
+		}
+	}
+	logrus.Infof("%d packets replayed from %s", cnt, filename)
+	return nil
+}
+
+func newPacketReader(r *bufio.Reader) (packetReader, error) {
+	magic, err := r.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(magic, pcapngMagic) {
+		return pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+	}
+	return pcapgo.NewReader(r)
+}
@@ -0,0 +1,181 @@
+package dump
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/types"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// replayed is a record replayed from fixture, captured at offset from fixtureEpoch
+type replayed struct {
+	offset time.Duration
+	record entity.RawTrafficRecord
+}
+
+func ipSet(ips ...string) map[string]struct{} {
+	ret := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		ret[ip] = struct{}{}
+	}
+	return ret
+}
+
+func raw(src, dst string, size uint64, protocol string, srcPort, dstPort uint16) entity.RawTrafficRecord {
+	return entity.RawTrafficRecord{SrcIP: src, DstIP: dst, Size: size, Protocol: protocol, SrcPort: srcPort, DstPort: dstPort}
+}
+
+func TestReplay(t *testing.T) {
+	probeIPs := ipSet("10.0.0.1", "10.0.0.3", "fd00::1")
+	centerIPs := ipSet("10.0.0.100", "fd00::100")
+
+	tests := []struct {
+		name         string
+		file         string
+		probeIPs     map[string]struct{}
+		centerIPs    map[string]struct{}
+		servicePorts []uint16
+		want         []replayed
+	}{
+		{
+			name:      "ipv4 over ethernet",
+			file:      "ipv4.pcap",
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []replayed{
+				{0, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 0, 80)},
+				{500 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 154, "tcp", 0, 80)},
+				{600 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 1054, "tcp", 80, 0)},
+				{700 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 62, "udp", 0, 53)},
+				{800 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 60, "icmp", 0, 0)},
+				{1200 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "sctp", 5000, 3868)},
+				{1300 * time.Millisecond, raw("10.0.0.1", "10.0.0.3", 60, "tcp", 0, 8080)},
+				{1400 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 0, 80)},
+			},
+		},
+		{
+			name:     "traffic with center kept without center IPs",
+			file:     "ipv4.pcap",
+			probeIPs: ipSet("10.0.0.1"),
+			want: []replayed{
+				{0, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 0, 80)},
+				{500 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 154, "tcp", 0, 80)},
+				{600 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 1054, "tcp", 80, 0)},
+				{700 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 62, "udp", 0, 53)},
+				{800 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 60, "icmp", 0, 0)},
+				{900 * time.Millisecond, raw("10.0.0.1", "10.0.0.100", 64, "tcp", 0, 10080)},
+				{900 * time.Millisecond, raw("10.0.0.100", "10.0.0.1", 64, "tcp", 10080, 0)},
+				{1200 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "sctp", 5000, 3868)},
+				{1300 * time.Millisecond, raw("10.0.0.1", "10.0.0.3", 60, "tcp", 0, 8080)},
+				{1400 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 0, 80)},
+			},
+		},
+		{
+			name:         "service ports",
+			file:         "ipv4.pcap",
+			probeIPs:     probeIPs,
+			centerIPs:    centerIPs,
+			servicePorts: []uint16{80, 40000},
+			want: []replayed{
+				{0, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 40000, 80)},
+				{500 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 154, "tcp", 40000, 80)},
+				{600 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 1054, "tcp", 80, 40000)},
+				{700 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 62, "udp", 0, 0)},
+				{800 * time.Millisecond, raw("10.0.0.1", "10.0.0.2", 60, "icmp", 0, 0)},
+				{1200 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "sctp", 0, 0)},
+				{1300 * time.Millisecond, raw("10.0.0.1", "10.0.0.3", 60, "tcp", 0, 0)},
+				{1400 * time.Millisecond, raw("10.0.0.2", "10.0.0.1", 60, "tcp", 40000, 80)},
+			},
+		},
+		{
+			name:      "ipv6 with extension headers",
+			file:      "ipv6.pcapng",
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []replayed{
+				{0, raw("fd00::2", "fd00::1", 74, "tcp", 0, 443)},
+				{100 * time.Millisecond, raw("fd00::1", "fd00::2", 90, "udp", 0, 53)},
+				{200 * time.Millisecond, raw("fd00::1", "fd00::2", 190, "tcp", 443, 0)},
+				{300 * time.Millisecond, raw("fd00::2", "fd00::1", 170, "udp", 0, 514)},
+				{400 * time.Millisecond, raw("fd00::1", "fd00::2", 62, "icmpv6", 0, 0)},
+			},
+		},
+		{
+			name:      "raw ip",
+			file:      "raw.pcap",
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []replayed{
+				{0, raw("10.0.0.2", "10.0.0.1", 40, "tcp", 0, 80)},
+				{100 * time.Millisecond, raw("fd00::1", "fd00::2", 68, "udp", 0, 53)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := &Decoder{
+				ProbeIPs:         tt.probeIPs,
+				CenterIPs:        tt.centerIPs,
+				ServicePorts:     tt.servicePorts,
+				EphemeralPortMin: 32768,
+				Counter:          types.NewCaptureCounter(tt.file),
+			}
+			got := make([]replayed, 0)
+			err := Replay(path.Join("testdata", tt.file), dec, func(rd *entity.RawTrafficRecord, ts time.Time) {
+				got = append(got, replayed{ts.Sub(fixtureEpoch), *rd})
+			})
+			if err != nil {
+				t.Fatalf("failed to replay %s: %s", tt.file, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayed records mismatch\ngot:  %v\nwant: %v", got, tt.want)
+			}
+
+			var bytes uint64
+			for _, r := range tt.want {
+				bytes += r.record.Size
+			}
+			stats := dec.Counter.Get()
+			if stats.Bytes != bytes {
+				t.Errorf("bytes counted %d, want %d", stats.Bytes, bytes)
+			}
+			if stats.DecodeFailures != 0 {
+				t.Errorf("decode failures counted %d, want 0", stats.DecodeFailures)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	dec := &Decoder{
+		ProbeIPs:         ipSet("10.0.0.1"),
+		EphemeralPortMin: 32768,
+		LinkType:         layers.LinkTypeEthernet,
+		Counter:          types.NewCaptureCounter("eth0"),
+	}
+	if err := dec.Init(); err != nil {
+		t.Fatalf("failed to init decoder: %s", err)
+	}
+
+	data := fixtures[0].packets[0].data[:24] // cut inside the IPv4 header
+	ci := gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}
+	if rd := dec.Decode(data, ci); rd != nil {
+		t.Errorf("truncated packet decoded into %v", rd)
+	}
+	if n := dec.Counter.Get().DecodeFailures; n != 1 {
+		t.Errorf("decode failures counted %d, want 1", n)
+	}
+}
+
+func TestDecoderUnsupportedLinkType(t *testing.T) {
+	dec := &Decoder{LinkType: layers.LinkTypeIEEE802_11}
+	if err := dec.Init(); err == nil {
+		t.Errorf("link type %s accepted", dec.LinkType)
+	}
+}
@@ -0,0 +1,11 @@
+package entity
+
+// CaptureStats the statistics of capturing on a network device, telling if the traffic is undercounted
+type CaptureStats struct {
+	Iface          string `json:"iface"`          // Iface name of the network device
+	Packets        uint64 `json:"packets"`        // Packets count of packets read from the network device
+	Drops          uint64 `json:"drops"`          // Drops count of packets dropped by kernel as the ring buffer overflows
+	DecodeFailures uint64 `json:"decodeFailures"` // DecodeFailures count of packets failed to decode
+	ReadErrors     uint64 `json:"readErrors"`     // ReadErrors count of errors reading the network device
+	Bytes          uint64 `json:"bytes"`          // Bytes size of traffic accounted in records
+}
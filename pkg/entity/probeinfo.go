@@ -0,0 +1,11 @@
+package entity
+
+// ProbeInfo the identity of probe and the workload it runs alongside, attached to the records it reports
+type ProbeInfo struct {
+	ID        string            `json:"id"`                  // ID stable ID of probe, kept across restarts
+	Hostname  string            `json:"hostname"`            // Hostname host name of probe, pod name in K8S
+	Node      string            `json:"node,omitempty"`      // Node name of the K8S node
+	Pod       string            `json:"pod,omitempty"`       // Pod name of the K8S pod
+	Namespace string            `json:"namespace,omitempty"` // Namespace namespace of the K8S pod
+	Labels    map[string]string `json:"labels,omitempty"`    // Labels labels of the workload, like the ones of K8S pod
+}
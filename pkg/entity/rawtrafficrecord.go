@@ -1,15 +1,63 @@
 package entity
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
 
 // RawTrafficRecord describe the original data collected by wakizashi's traffic probe
 type RawTrafficRecord struct {
-	SrcIP string
-	DstIP string
-	Size  uint64
+	SrcIP    string
+	DstIP    string
+	Size     uint64
+	Protocol string // layer-4 protocol, like tcp, udp, icmp
+	SrcPort  uint16 // source service port, 0 if ephemeral or not applicable
+	DstPort  uint16 // destination service port, 0 if ephemeral or not applicable
 }
 
-// ToString convert the data to string
+// ToString convert the data to string, fields are separated by space so that IPv6 address is kept intact
 func (rtr *RawTrafficRecord) ToString() string {
-	return fmt.Sprintf("%s %s %d", rtr.SrcIP, rtr.DstIP, rtr.Size)
+	return fmt.Sprintf("%s %s %d %s %d %d", rtr.SrcIP, rtr.DstIP, rtr.Size, rtr.Protocol, rtr.SrcPort, rtr.DstPort)
+}
+
+// ParseRawTrafficRecord parse the string generated by RawTrafficRecord.ToString,
+// lines without protocol & ports generated by probe of older version are accepted as well
+func ParseRawTrafficRecord(line string) (*RawTrafficRecord, error) {
+	elems := strings.Split(line, " ")
+	if len(elems) != 3 && len(elems) != 6 {
+		return nil, fmt.Errorf("invalid count of fields %d", len(elems))
+	}
+
+	srcIP := net.ParseIP(elems[0])
+	dstIP := net.ParseIP(elems[1])
+	if srcIP == nil || dstIP == nil {
+		return nil, fmt.Errorf("invalid IP address")
+	}
+	sz, err := strconv.ParseUint(elems[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract size, detail: %s", err)
+	}
+	ret := &RawTrafficRecord{
+		SrcIP: srcIP.String(),
+		DstIP: dstIP.String(),
+		Size:  sz,
+	}
+	if len(elems) == 3 {
+		return ret, nil
+	}
+
+	ret.Protocol = elems[3]
+	srcPort, err := strconv.ParseUint(elems[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract source port, detail: %s", err)
+	}
+	dstPort, err := strconv.ParseUint(elems[5], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract destination port, detail: %s", err)
+	}
+	ret.SrcPort = uint16(srcPort)
+	ret.DstPort = uint16(dstPort)
+	return ret, nil
 }
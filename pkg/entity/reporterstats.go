@@ -0,0 +1,11 @@
+package entity
+
+// ReporterStats the state of reporter's connection to center, telling if the records are reported or piling up
+type ReporterStats struct {
+	State          string `json:"state"`                    // State connecting, connected, backoff or stopped
+	ConnectedSince int64  `json:"connectedSince,omitempty"` // ConnectedSince unix time when current stream to center is established
+	Failures       int    `json:"failures"`                 // Failures count of consecutive failed attempts to report to center
+	RetryAt        int64  `json:"retryAt,omitempty"`        // RetryAt unix time of next attempt, when backing off
+	LastError      string `json:"lastError,omitempty"`      // LastError error of the last failed attempt
+	LastErrorTime  int64  `json:"lastErrorTime,omitempty"`  // LastErrorTime unix time of the last failed attempt
+}
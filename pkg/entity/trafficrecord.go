@@ -4,11 +4,33 @@ import "encoding/json"
 
 // TrafficRecord the record of the traffic detected
 type TrafficRecord struct {
-	Timestamp int64  `json:"timestamp"` // Timestamp when the traffic record is generated
-	ProbeIP   string `json:"probeIP"`   // ProbeIP where is probe is collecting traffic data
-	SrcIP     string `json:"srcIP"`     // SrcIP source IP of the traffic
-	DstIP     string `json:"dstIP"`     // DstIP destination IP of the traffic
-	Size      uint64 `json:"size"`      // Size size of the traffic
+	Timestamp int64             `json:"timestamp"`           // Timestamp when the traffic record is generated
+	ProbeIP   string            `json:"probeIP"`             // ProbeIP where is probe is collecting traffic data
+	SrcIP     string            `json:"srcIP"`               // SrcIP source IP of the traffic
+	DstIP     string            `json:"dstIP"`               // DstIP destination IP of the traffic
+	Size      uint64            `json:"size"`                // Size size of the traffic
+	Protocol  string            `json:"protocol"`            // Protocol layer-4 protocol of the traffic
+	SrcPort   uint16            `json:"srcPort"`             // SrcPort source service port of the traffic, 0 if ephemeral
+	DstPort   uint16            `json:"dstPort"`             // DstPort destination service port of the traffic, 0 if ephemeral
+	Direction string            `json:"direction"`           // Direction ingress, egress or local, from the view of probe
+	Packets   uint64            `json:"packets"`             // Packets count of packets of the traffic
+	Tenant    string            `json:"tenant,omitempty"`    // Tenant the probe belongs to, resolved by center from its token
+	ProbeID   string            `json:"probeID,omitempty"`   // ProbeID stable ID of the probe, unlike ProbeIP it is not recycled
+	Hostname  string            `json:"hostname,omitempty"`  // Hostname host name of the probe
+	Node      string            `json:"node,omitempty"`      // Node name of the K8S node the probe runs on
+	Pod       string            `json:"pod,omitempty"`       // Pod name of the K8S pod the probe runs in
+	Namespace string            `json:"namespace,omitempty"` // Namespace namespace of the K8S pod the probe runs in
+	Labels    map[string]string `json:"labels,omitempty"`    // Labels labels of the workload the probe runs alongside
+}
+
+// SetProbeInfo attaches the identity of probe to the record
+func (t *TrafficRecord) SetProbeInfo(info *ProbeInfo) {
+	t.ProbeID = info.ID
+	t.Hostname = info.Hostname
+	t.Node = info.Node
+	t.Pod = info.Pod
+	t.Namespace = info.Namespace
+	t.Labels = info.Labels
 }
 
 // ToJSONString convert the TrafficRecord to JSON string if not error
@@ -0,0 +1,432 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+// offsets in network layer header
+const (
+	offIPv4Frag  = 6
+	offIPv4Proto = 9
+	offIPv4Src   = 12
+	offIPv4Dst   = 16
+	offIPv6Next  = 6
+	offIPv6Src   = 8
+	offIPv6Dst   = 24
+	offIPv6Ext   = 40 // the header following IPv6 header, like the layer-4 header or the fragment header
+	offARPSrc    = 14
+	offARPDst    = 24
+
+	offEtherType     = 12
+	lenEtherHeader   = 14
+	etherTypeIPv4    = 0x0800
+	etherTypeIPv6    = 0x86dd
+	etherTypeARP     = 0x0806
+	etherTypeRARP    = 0x8035
+	ipProtoFragment  = 44
+	maxProgramLength = 4096
+)
+
+var ipProtocols = map[string]uint32{
+	"tcp":   6,
+	"udp":   17,
+	"sctp":  132,
+	"icmp":  1,
+	"icmp6": 58,
+}
+
+// Compile compiles the filter expression into classic BPF program for the packets of given link type,
+// which accepts at most snapLen bytes of a matching packet. Only ethernet and raw IP are supported.
+func Compile(expr string, linkType layers.LinkType, snapLen int) ([]bpf.RawInstruction, error) {
+	lk, err := newLink(linkType)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter `%s`, detail: %s", expr, err)
+	}
+
+	c := &compiler{link: lk}
+	accept, reject := c.newLabel(), c.newLabel()
+	c.gen(tree, accept, reject)
+	c.place(accept)
+	c.emit(op{ins: bpf.RetConstant{Val: uint32(snapLen)}})
+	c.place(reject)
+	c.emit(op{ins: bpf.RetConstant{Val: 0}})
+
+	prog, err := c.assemble()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile filter `%s`, detail: %s", expr, err)
+	}
+	return prog, nil
+}
+
+// op is a BPF instruction whose jump targets are labels to be resolved
+type op struct {
+	ins      bpf.Instruction
+	cond     bpf.JumpTest
+	val      uint32
+	onTrue   int
+	onFalse  int
+	isCond   bool
+	long     bool // conditional jump too far for 8-bit offsets, expanded to jump-if and two long jumps
+	position int
+}
+
+type compiler struct {
+	link   *link
+	ops    []op
+	labels []int // label -> index of the op it points to
+}
+
+func (c *compiler) newLabel() int {
+	c.labels = append(c.labels, -1)
+	return len(c.labels) - 1
+}
+
+func (c *compiler) place(label int) {
+	c.labels[label] = len(c.ops)
+}
+
+func (c *compiler) emit(o op) {
+	c.ops = append(c.ops, o)
+}
+
+// test emits the loads followed by a conditional jump
+func (c *compiler) test(loads []bpf.Instruction, cond bpf.JumpTest, val uint32, onTrue, onFalse int) {
+	for _, ins := range loads {
+		c.emit(op{ins: ins})
+	}
+	c.emit(op{isCond: true, cond: cond, val: val, onTrue: onTrue, onFalse: onFalse})
+}
+
+func (c *compiler) gen(n node, onTrue, onFalse int) {
+	switch n := n.(type) {
+	case *andNode:
+		next := c.newLabel()
+		c.gen(n.left, next, onFalse)
+		c.place(next)
+		c.gen(n.right, onTrue, onFalse)
+	case *orNode:
+		next := c.newLabel()
+		c.gen(n.left, onTrue, next)
+		c.place(next)
+		c.gen(n.right, onTrue, onFalse)
+	case *notNode:
+		c.gen(n.operand, onFalse, onTrue)
+	case *test:
+		c.test(n.loads, n.cond, n.val, onTrue, onFalse)
+	case *primitive:
+		c.gen(c.link.expand(n), onTrue, onFalse)
+	}
+}
+
+// assemble resolves the labels and assembles the program
+func (c *compiler) assemble() ([]bpf.RawInstruction, error) {
+	// conditional jumps only take 8-bit offsets, so expand those too far until the layout is stable
+	for {
+		pos := 0
+		for i := range c.ops {
+			c.ops[i].position = pos
+			pos++
+			if c.ops[i].long {
+				pos += 2
+			}
+		}
+		if pos > maxProgramLength {
+			return nil, fmt.Errorf("program too long (%d instructions)", pos)
+		}
+		changed := false
+		for i := range c.ops {
+			o := &c.ops[i]
+			if !o.isCond || o.long {
+				continue
+			}
+			if c.distance(o, o.onTrue) > 255 || c.distance(o, o.onFalse) > 255 {
+				o.long = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var prog []bpf.Instruction
+	for i := range c.ops {
+		o := &c.ops[i]
+		switch {
+		case o.isCond && o.long:
+			prog = append(prog,
+				bpf.JumpIf{Cond: o.cond, Val: o.val, SkipTrue: 0, SkipFalse: 1},
+				bpf.Jump{Skip: uint32(c.distance(o, o.onTrue) - 1)},
+				bpf.Jump{Skip: uint32(c.distance(o, o.onFalse) - 2)},
+			)
+		case o.isCond:
+			prog = append(prog, bpf.JumpIf{
+				Cond:      o.cond,
+				Val:       o.val,
+				SkipTrue:  uint8(c.distance(o, o.onTrue)),
+				SkipFalse: uint8(c.distance(o, o.onFalse)),
+			})
+		default:
+			prog = append(prog, o.ins)
+		}
+	}
+	return bpf.Assemble(prog)
+}
+
+// distance gives the number of instructions to skip from the op to reach the label
+func (c *compiler) distance(o *op, label int) int {
+	return c.ops[c.labels[label]].position - o.position - 1
+}
+
+// test is an atomic test on packet, which loads a value then compares it
+type test struct {
+	loads []bpf.Instruction
+	cond  bpf.JumpTest
+	val   uint32
+}
+
+func and(nodes ...node) node {
+	ret := nodes[0]
+	for _, n := range nodes[1:] {
+		ret = &andNode{ret, n}
+	}
+	return ret
+}
+
+func or(nodes ...node) node {
+	ret := nodes[0]
+	for _, n := range nodes[1:] {
+		ret = &orNode{ret, n}
+	}
+	return ret
+}
+
+func loadAbs(off, size uint32) []bpf.Instruction {
+	return []bpf.Instruction{bpf.LoadAbsolute{Off: off, Size: int(size)}}
+}
+
+func equal(loads []bpf.Instruction, val uint32) node {
+	return &test{loads: loads, cond: bpf.JumpEqual, val: val}
+}
+
+func masked(loads []bpf.Instruction, mask, val uint32) node {
+	if mask == 0xffffffff {
+		return equal(loads, val)
+	}
+	loads = append(loads, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: mask})
+	return equal(loads, val&mask)
+}
+
+// never is a test that never matches, without touching the packet
+func never() node {
+	return &test{loads: []bpf.Instruction{bpf.LoadConstant{Dst: bpf.RegA, Val: 0}}, cond: bpf.JumpEqual, val: 1}
+}
+
+// link tells where the network layer header starts and which network protocol it is, by the link type
+type link struct {
+	linkType layers.LinkType
+	nh       uint32 // offset of network layer header
+}
+
+func newLink(linkType layers.LinkType) (*link, error) {
+	switch linkType {
+	case layers.LinkTypeEthernet:
+		return &link{linkType: linkType, nh: lenEtherHeader}, nil
+	case layers.LinkTypeRaw:
+		// the IP version tells IPv4 from IPv6, like the packets on an IPIP tunnel
+		return &link{linkType: linkType, nh: 0}, nil
+	}
+	return nil, fmt.Errorf("unsupported link type %s", linkType)
+}
+
+// etherType tests the ether type, raw IP packets are told apart by IP version
+func (l *link) etherType(t uint32) node {
+	if l.linkType == layers.LinkTypeEthernet {
+		return equal(loadAbs(offEtherType, 2), t)
+	}
+	switch t {
+	case etherTypeIPv4:
+		return masked(loadAbs(0, 1), 0xf0, 0x40)
+	case etherTypeIPv6:
+		return masked(loadAbs(0, 1), 0xf0, 0x60)
+	}
+	return never()
+}
+
+func (l *link) load(off, size uint32) []bpf.Instruction {
+	return loadAbs(l.nh+off, size)
+}
+
+func (l *link) ipv4Proto(p uint32) node {
+	return and(l.etherType(etherTypeIPv4), equal(l.load(offIPv4Proto, 1), p))
+}
+
+// ipv6Next tests the next header of IPv6, or the one following the fragment header like libpcap does
+func (l *link) ipv6Next(p uint32) node {
+	return and(l.etherType(etherTypeIPv6), or(
+		equal(l.load(offIPv6Next, 1), p),
+		and(equal(l.load(offIPv6Next, 1), ipProtoFragment), equal(l.load(offIPv6Ext, 1), p)),
+	))
+}
+
+// expand expands the primitive into atomic tests
+func (l *link) expand(p *primitive) node {
+	switch p.kind {
+	case "proto":
+		return l.expandProto(p.proto)
+	case "host":
+		var hosts []node
+		for _, ip := range p.ips {
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				bits = 32
+			}
+			hosts = append(hosts, l.expandAddress(p, ip, net.CIDRMask(bits, bits)))
+		}
+		return or(hosts...)
+	case "net":
+		return l.expandAddress(p, p.ipnet.IP, p.ipnet.Mask)
+	case "port":
+		return l.expandPort(p)
+	case "greater":
+		return &test{loads: []bpf.Instruction{bpf.LoadExtension{Num: bpf.ExtLen}}, cond: bpf.JumpGreaterOrEqual, val: p.length}
+	case "less":
+		return &test{loads: []bpf.Instruction{bpf.LoadExtension{Num: bpf.ExtLen}}, cond: bpf.JumpLessOrEqual, val: p.length}
+	}
+	return nil
+}
+
+func (l *link) expandProto(proto string) node {
+	switch proto {
+	case "ip":
+		return l.etherType(etherTypeIPv4)
+	case "ip6":
+		return l.etherType(etherTypeIPv6)
+	case "arp":
+		return l.etherType(etherTypeARP)
+	case "icmp":
+		return l.ipv4Proto(ipProtocols[proto])
+	case "icmp6":
+		return l.ipv6Next(ipProtocols[proto])
+	}
+	return or(l.ipv6Next(ipProtocols[proto]), l.ipv4Proto(ipProtocols[proto]))
+}
+
+// expandAddress tests the addresses of IP, and those of ARP & RARP for an IPv4 address not qualified by ip, like libpcap does
+func (l *link) expandAddress(p *primitive, ip net.IP, mask net.IPMask) node {
+	v4 := true
+	if ip4 := ip.To4(); ip4 != nil && len(mask) == net.IPv4len {
+		ip = ip4
+	} else {
+		ip, mask = ip.To16(), net.IPMask(net.IP(mask).To16())
+		v4 = false
+	}
+	if (p.proto == "ip" && !v4) || (p.proto == "ip6" && v4) {
+		// never matches, like `ip host ::1`
+		return never()
+	}
+
+	match := func(off uint32) node {
+		var words []node
+		for i := 0; i < len(ip); i += 4 {
+			m := binary.BigEndian.Uint32(mask[i : i+4])
+			if m == 0 {
+				continue
+			}
+			words = append(words, masked(l.load(off+uint32(i), 4), m, binary.BigEndian.Uint32(ip[i:i+4])))
+		}
+		if len(words) == 0 {
+			// zero length mask, matches any address
+			return nil
+		}
+		return and(words...)
+	}
+	addr := func(ethType uint32, srcOff, dstOff uint32) node {
+		var ret node
+		switch p.dir {
+		case "src":
+			ret = match(srcOff)
+		case "dst":
+			ret = match(dstOff)
+		default:
+			if src := match(srcOff); src != nil {
+				ret = or(src, match(dstOff))
+			}
+		}
+		if ret == nil {
+			return l.etherType(ethType)
+		}
+		return and(l.etherType(ethType), ret)
+	}
+
+	if !v4 {
+		return addr(etherTypeIPv6, offIPv6Src, offIPv6Dst)
+	}
+	ret := addr(etherTypeIPv4, offIPv4Src, offIPv4Dst)
+	if p.proto == "" && l.linkType == layers.LinkTypeEthernet {
+		ret = or(ret, addr(etherTypeARP, offARPSrc, offARPDst), addr(etherTypeRARP, offARPSrc, offARPDst))
+	}
+	return ret
+}
+
+func (l *link) expandPort(p *primitive) node {
+	protos := []string{"tcp", "udp", "sctp"}
+	if p.proto != "" {
+		protos = []string{p.proto}
+	}
+
+	inRange := func(loads []bpf.Instruction) node {
+		if p.portMin == p.portMax {
+			return equal(loads, uint32(p.portMin))
+		}
+		return and(
+			&test{loads: loads, cond: bpf.JumpGreaterOrEqual, val: uint32(p.portMin)},
+			&test{loads: loads, cond: bpf.JumpLessOrEqual, val: uint32(p.portMax)},
+		)
+	}
+	ports := func(load func(off uint32) []bpf.Instruction) node {
+		switch p.dir {
+		case "src":
+			return inRange(load(0))
+		case "dst":
+			return inRange(load(2))
+		}
+		return or(inRange(load(0)), inRange(load(2)))
+	}
+
+	var v4Protos, v6Protos []node
+	for _, proto := range protos {
+		v4Protos = append(v4Protos, equal(l.load(offIPv4Proto, 1), ipProtocols[proto]))
+		v6Protos = append(v6Protos, equal(l.load(offIPv6Next, 1), ipProtocols[proto]))
+	}
+	v4 := and(
+		l.etherType(etherTypeIPv4),
+		or(v4Protos...),
+		// only the first fragment carries the ports
+		&test{loads: l.load(offIPv4Frag, 2), cond: bpf.JumpBitsNotSet, val: 0x1fff},
+		ports(func(off uint32) []bpf.Instruction {
+			return []bpf.Instruction{
+				bpf.LoadMemShift{Off: l.nh},
+				bpf.LoadIndirect{Off: l.nh + off, Size: 2},
+			}
+		}),
+	)
+	// ports are loaded at fixed offset, IPv6 extension headers are not walked through like libpcap
+	v6 := and(
+		l.etherType(etherTypeIPv6),
+		or(v6Protos...),
+		ports(func(off uint32) []bpf.Instruction {
+			return l.load(offIPv6Ext+off, 2)
+		}),
+	)
+	return or(v6, v4)
+}
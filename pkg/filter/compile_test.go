@@ -0,0 +1,692 @@
+package filter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+const goldenSnapLen = 262144
+
+// sockFilter is an instruction in the format dumped by tcpdump -dd, like struct sock_filter
+type sockFilter struct {
+	code   uint16
+	jt, jf uint8
+	k      uint32
+}
+
+// goldens are the programs dumped by `tcpdump -y EN10MB -dd <expr>` and `tcpdump -y RAW -dd <expr>`,
+// the compiled programs are expected to accept & reject the same packets as them, though not identical
+var goldens = []struct {
+	linkType layers.LinkType
+	expr     string
+	prog     []sockFilter
+}{
+	{layers.LinkTypeEthernet, "ip", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 1, 0x00000800},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "ip6", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 1, 0x000086dd},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "arp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 1, 0x00000806},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "not arp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 1, 0x00000806},
+		{0x6, 0, 0, 0x00000000},
+		{0x6, 0, 0, 0x00040000},
+	}},
+	{layers.LinkTypeEthernet, "tcp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 5, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 6, 0, 0x00000006},
+		{0x15, 0, 6, 0x0000002c},
+		{0x30, 0, 0, 0x00000036},
+		{0x15, 3, 4, 0x00000006},
+		{0x15, 0, 3, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 1, 0x00000006},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "udp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 5, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 6, 0, 0x00000011},
+		{0x15, 0, 6, 0x0000002c},
+		{0x30, 0, 0, 0x00000036},
+		{0x15, 3, 4, 0x00000011},
+		{0x15, 0, 3, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 1, 0x00000011},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "sctp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 5, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 6, 0, 0x00000084},
+		{0x15, 0, 6, 0x0000002c},
+		{0x30, 0, 0, 0x00000036},
+		{0x15, 3, 4, 0x00000084},
+		{0x15, 0, 3, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 1, 0x00000084},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "icmp", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 3, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 1, 0x00000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "icmp6", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 6, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 3, 0, 0x0000003a},
+		{0x15, 0, 3, 0x0000002c},
+		{0x30, 0, 0, 0x00000036},
+		{0x15, 0, 1, 0x0000003a},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "host 10.0.0.1", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 4, 0x00000800},
+		{0x20, 0, 0, 0x0000001a},
+		{0x15, 8, 0, 0x0a000001},
+		{0x20, 0, 0, 0x0000001e},
+		{0x15, 6, 7, 0x0a000001},
+		{0x15, 1, 0, 0x00000806},
+		{0x15, 0, 5, 0x00008035},
+		{0x20, 0, 0, 0x0000001c},
+		{0x15, 2, 0, 0x0a000001},
+		{0x20, 0, 0, 0x00000026},
+		{0x15, 0, 1, 0x0a000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "src host 10.0.0.1", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 2, 0x00000800},
+		{0x20, 0, 0, 0x0000001a},
+		{0x15, 4, 5, 0x0a000001},
+		{0x15, 1, 0, 0x00000806},
+		{0x15, 0, 3, 0x00008035},
+		{0x20, 0, 0, 0x0000001c},
+		{0x15, 0, 1, 0x0a000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "dst host 10.0.0.1", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 2, 0x00000800},
+		{0x20, 0, 0, 0x0000001e},
+		{0x15, 4, 5, 0x0a000001},
+		{0x15, 1, 0, 0x00000806},
+		{0x15, 0, 3, 0x00008035},
+		{0x20, 0, 0, 0x00000026},
+		{0x15, 0, 1, 0x0a000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "ip host 10.0.0.1", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 5, 0x00000800},
+		{0x20, 0, 0, 0x0000001a},
+		{0x15, 2, 0, 0x0a000001},
+		{0x20, 0, 0, 0x0000001e},
+		{0x15, 0, 1, 0x0a000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "host fd00::1", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 17, 0x000086dd},
+		{0x20, 0, 0, 0x00000016},
+		{0x15, 0, 6, 0xfd000000},
+		{0x20, 0, 0, 0x0000001a},
+		{0x15, 0, 4, 0x00000000},
+		{0x20, 0, 0, 0x0000001e},
+		{0x15, 0, 2, 0x00000000},
+		{0x20, 0, 0, 0x00000022},
+		{0x15, 8, 0, 0x00000001},
+		{0x20, 0, 0, 0x00000026},
+		{0x15, 0, 7, 0xfd000000},
+		{0x20, 0, 0, 0x0000002a},
+		{0x15, 0, 5, 0x00000000},
+		{0x20, 0, 0, 0x0000002e},
+		{0x15, 0, 3, 0x00000000},
+		{0x20, 0, 0, 0x00000032},
+		{0x15, 0, 1, 0x00000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "net 10.0.0.0/8", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 6, 0x00000800},
+		{0x20, 0, 0, 0x0000001a},
+		{0x54, 0, 0, 0xff000000},
+		{0x15, 11, 0, 0x0a000000},
+		{0x20, 0, 0, 0x0000001e},
+		{0x54, 0, 0, 0xff000000},
+		{0x15, 8, 9, 0x0a000000},
+		{0x15, 1, 0, 0x00000806},
+		{0x15, 0, 7, 0x00008035},
+		{0x20, 0, 0, 0x0000001c},
+		{0x54, 0, 0, 0xff000000},
+		{0x15, 3, 0, 0x0a000000},
+		{0x20, 0, 0, 0x00000026},
+		{0x54, 0, 0, 0xff000000},
+		{0x15, 0, 1, 0x0a000000},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "net fd00::/64", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 9, 0x000086dd},
+		{0x20, 0, 0, 0x00000016},
+		{0x15, 0, 2, 0xfd000000},
+		{0x20, 0, 0, 0x0000001a},
+		{0x15, 4, 0, 0x00000000},
+		{0x20, 0, 0, 0x00000026},
+		{0x15, 0, 3, 0xfd000000},
+		{0x20, 0, 0, 0x0000002a},
+		{0x15, 0, 1, 0x00000000},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "port 80", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 8, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 17, 0x00000011},
+		{0x28, 0, 0, 0x00000036},
+		{0x15, 14, 0, 0x00000050},
+		{0x28, 0, 0, 0x00000038},
+		{0x15, 12, 13, 0x00000050},
+		{0x15, 0, 12, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 8, 0x00000011},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 6, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x0000000e},
+		{0x15, 2, 0, 0x00000050},
+		{0x48, 0, 0, 0x00000010},
+		{0x15, 0, 1, 0x00000050},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "port 53", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 8, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 17, 0x00000011},
+		{0x28, 0, 0, 0x00000036},
+		{0x15, 14, 0, 0x00000035},
+		{0x28, 0, 0, 0x00000038},
+		{0x15, 12, 13, 0x00000035},
+		{0x15, 0, 12, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 8, 0x00000011},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 6, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x0000000e},
+		{0x15, 2, 0, 0x00000035},
+		{0x48, 0, 0, 0x00000010},
+		{0x15, 0, 1, 0x00000035},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "port 10080", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 8, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 17, 0x00000011},
+		{0x28, 0, 0, 0x00000036},
+		{0x15, 14, 0, 0x00002760},
+		{0x28, 0, 0, 0x00000038},
+		{0x15, 12, 13, 0x00002760},
+		{0x15, 0, 12, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 8, 0x00000011},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 6, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x0000000e},
+		{0x15, 2, 0, 0x00002760},
+		{0x48, 0, 0, 0x00000010},
+		{0x15, 0, 1, 0x00002760},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "src port 53", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 6, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 13, 0x00000011},
+		{0x28, 0, 0, 0x00000036},
+		{0x15, 10, 11, 0x00000035},
+		{0x15, 0, 10, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 6, 0x00000011},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 4, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x0000000e},
+		{0x15, 0, 1, 0x00000035},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "tcp dst port 443", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 4, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 0, 11, 0x00000006},
+		{0x28, 0, 0, 0x00000038},
+		{0x15, 8, 9, 0x000001bb},
+		{0x15, 0, 8, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 6, 0x00000006},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 4, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x00000010},
+		{0x15, 0, 1, 0x000001bb},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "udp portrange 1000-2000", []sockFilter{
+		{0x28, 0, 0, 0x0000000c},
+		{0x15, 0, 8, 0x000086dd},
+		{0x30, 0, 0, 0x00000014},
+		{0x15, 0, 19, 0x00000011},
+		{0x28, 0, 0, 0x00000036},
+		{0x35, 0, 1, 0x000003e8},
+		{0x25, 0, 15, 0x000007d0},
+		{0x28, 0, 0, 0x00000038},
+		{0x35, 0, 14, 0x000003e8},
+		{0x25, 13, 12, 0x000007d0},
+		{0x15, 0, 12, 0x00000800},
+		{0x30, 0, 0, 0x00000017},
+		{0x15, 0, 10, 0x00000011},
+		{0x28, 0, 0, 0x00000014},
+		{0x45, 8, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x0000000e},
+		{0x48, 0, 0, 0x0000000e},
+		{0x35, 0, 1, 0x000003e8},
+		{0x25, 0, 3, 0x000007d0},
+		{0x48, 0, 0, 0x00000010},
+		{0x35, 0, 2, 0x000003e8},
+		{0x25, 1, 0, 0x000007d0},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "greater 100", []sockFilter{
+		{0x80, 0, 0, 0x00000000},
+		{0x35, 0, 1, 0x00000064},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeEthernet, "less 100", []sockFilter{
+		{0x80, 0, 0, 0x00000000},
+		{0x25, 1, 0, 0x00000064},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "ip", []sockFilter{
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 1, 0x00000040},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "ip6", []sockFilter{
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 1, 0x00000060},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "arp", []sockFilter{
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "tcp", []sockFilter{
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 5, 0x00000060},
+		{0x30, 0, 0, 0x00000006},
+		{0x15, 8, 0, 0x00000006},
+		{0x15, 0, 8, 0x0000002c},
+		{0x30, 0, 0, 0x00000028},
+		{0x15, 5, 6, 0x00000006},
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 3, 0x00000040},
+		{0x30, 0, 0, 0x00000009},
+		{0x15, 0, 1, 0x00000006},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "host 10.0.0.1", []sockFilter{
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 5, 0x00000040},
+		{0x20, 0, 0, 0x0000000c},
+		{0x15, 2, 0, 0x0a000001},
+		{0x20, 0, 0, 0x00000010},
+		{0x15, 0, 1, 0x0a000001},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+	{layers.LinkTypeRaw, "port 53", []sockFilter{
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 8, 0x00000060},
+		{0x30, 0, 0, 0x00000006},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 19, 0x00000011},
+		{0x28, 0, 0, 0x00000028},
+		{0x15, 16, 0, 0x00000035},
+		{0x28, 0, 0, 0x0000002a},
+		{0x15, 14, 15, 0x00000035},
+		{0x30, 0, 0, 0x00000000},
+		{0x54, 0, 0, 0x000000f0},
+		{0x15, 0, 12, 0x00000040},
+		{0x30, 0, 0, 0x00000009},
+		{0x15, 2, 0, 0x00000084},
+		{0x15, 1, 0, 0x00000006},
+		{0x15, 0, 8, 0x00000011},
+		{0x28, 0, 0, 0x00000006},
+		{0x45, 6, 0, 0x00001fff},
+		{0xb1, 0, 0, 0x00000000},
+		{0x48, 0, 0, 0x00000000},
+		{0x15, 2, 0, 0x00000035},
+		{0x48, 0, 0, 0x00000002},
+		{0x15, 0, 1, 0x00000035},
+		{0x6, 0, 0, 0x00040000},
+		{0x6, 0, 0, 0x00000000},
+	}},
+}
+
+// ref is the reference result of a compound expression, composed of the golden programs of its primitives
+type ref interface {
+	match(t *testing.T, linkType layers.LinkType, pkt []byte) bool
+}
+
+// refGolden matches as the golden program of expr
+type refGolden string
+
+type refAnd struct{ left, right ref }
+
+type refOr struct{ left, right ref }
+
+type refNot struct{ operand ref }
+
+func (r refGolden) match(t *testing.T, linkType layers.LinkType, pkt []byte) bool {
+	for _, g := range goldens {
+		if g.linkType == linkType && g.expr == string(r) {
+			return run(t, goldenProgram(g.prog), pkt) != 0
+		}
+	}
+	t.Fatalf("no golden of `%s` for %s", string(r), linkType)
+	return false
+}
+
+func (r refAnd) match(t *testing.T, linkType layers.LinkType, pkt []byte) bool {
+	return r.left.match(t, linkType, pkt) && r.right.match(t, linkType, pkt)
+}
+
+func (r refOr) match(t *testing.T, linkType layers.LinkType, pkt []byte) bool {
+	return r.left.match(t, linkType, pkt) || r.right.match(t, linkType, pkt)
+}
+
+func (r refNot) match(t *testing.T, linkType layers.LinkType, pkt []byte) bool {
+	return !r.operand.match(t, linkType, pkt)
+}
+
+// compounds combine the primitives of goldens, and & or are of equal precedence and evaluated from left to right
+// as pcap-filter(7) does, not binds tighter, so the references are grouped accordingly
+var compounds = []struct {
+	linkType layers.LinkType
+	expr     string
+	ref      ref
+}{
+	{layers.LinkTypeEthernet, "not port 10080 and not arp",
+		refAnd{refNot{refGolden("port 10080")}, refGolden("not arp")}},
+	{layers.LinkTypeEthernet, "tcp or udp and port 53",
+		refAnd{refOr{refGolden("tcp"), refGolden("udp")}, refGolden("port 53")}},
+	{layers.LinkTypeEthernet, "port 53 and tcp or udp",
+		refOr{refAnd{refGolden("port 53"), refGolden("tcp")}, refGolden("udp")}},
+	{layers.LinkTypeEthernet, "tcp or (udp and port 53)",
+		refOr{refGolden("tcp"), refAnd{refGolden("udp"), refGolden("port 53")}}},
+	{layers.LinkTypeEthernet, "not (tcp or udp) and host 10.0.0.1",
+		refAnd{refNot{refOr{refGolden("tcp"), refGolden("udp")}}, refGolden("host 10.0.0.1")}},
+	{layers.LinkTypeEthernet, "! arp && (ip6 || src port 53)",
+		refAnd{refGolden("not arp"), refOr{refGolden("ip6"), refGolden("src port 53")}}},
+	{layers.LinkTypeEthernet, "host 10.0.0.1 or host fd00::1 and not tcp dst port 443",
+		refAnd{refOr{refGolden("host 10.0.0.1"), refGolden("host fd00::1")}, refNot{refGolden("tcp dst port 443")}}},
+	{layers.LinkTypeEthernet, "not not (icmp or icmp6) or greater 100 and not ip6",
+		refAnd{refOr{refOr{refGolden("icmp"), refGolden("icmp6")}, refGolden("greater 100")}, refNot{refGolden("ip6")}}},
+	{layers.LinkTypeRaw, "tcp or ip6 and port 53",
+		refAnd{refOr{refGolden("tcp"), refGolden("ip6")}, refGolden("port 53")}},
+	{layers.LinkTypeRaw, "not (host 10.0.0.1 or port 53)",
+		refNot{refOr{refGolden("host 10.0.0.1"), refGolden("port 53")}}},
+}
+
+// corpus builds the packets to run the programs against, covering the addresses, protocols and ports in goldens,
+// ethernet frames are built if ether is set, otherwise raw IP packets
+func corpus(ether bool) [][]byte {
+	var ret [][]byte
+	build := func(ethType layers.EthernetType, ls ...gopacket.SerializableLayer) {
+		if ether {
+			eth := &layers.Ethernet{
+				SrcMAC:       net.HardwareAddr{2, 0, 0, 0, 0, 1},
+				DstMAC:       net.HardwareAddr{2, 0, 0, 0, 0, 2},
+				EthernetType: ethType,
+			}
+			ls = append([]gopacket.SerializableLayer{eth}, ls...)
+		} else if ethType != layers.EthernetTypeIPv4 && ethType != layers.EthernetTypeIPv6 {
+			return
+		}
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ls...); err != nil {
+			panic(err)
+		}
+		ret = append(ret, append([]byte(nil), buf.Bytes()...))
+	}
+
+	ip4s := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.1.2.3"), net.ParseIP("192.168.1.1")}
+	ip6s := []net.IP{net.ParseIP("fd00::1"), net.ParseIP("fd00::2"), net.ParseIP("fd00:0:0:1::5"), net.ParseIP("fe80::1")}
+	ports := []uint16{53, 80, 443, 999, 1000, 1500, 2000, 2001, 10080, 40000}
+	protos := []layers.IPProtocol{layers.IPProtocolTCP, layers.IPProtocolUDP, layers.IPProtocolSCTP}
+
+	// the layer-4 header, with payload of varied length so that greater & less are covered
+	l4 := func(proto layers.IPProtocol, src, dst uint16) gopacket.SerializableLayer {
+		payload := make([]byte, int(src%7)*10)
+		switch proto {
+		case layers.IPProtocolICMPv4, layers.IPProtocolICMPv6:
+			return gopacket.Payload(append([]byte{8, 0, 0, 0, 0, 1, 0, 1}, payload...))
+		}
+		hdr := []byte{byte(src >> 8), byte(src), byte(dst >> 8), byte(dst), 0, 0, 0, 0, 0, 0, 0, 0, 0x50, 0x10, 4, 0, 0, 0, 0, 0}
+		return gopacket.Payload(append(hdr, payload...))
+	}
+
+	for i, src := range ip4s {
+		dst := ip4s[(i+1)%len(ip4s)]
+		for _, proto := range append(protos, layers.IPProtocolICMPv4) {
+			for j, port := range ports {
+				peer := ports[(j+3)%len(ports)]
+				ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: proto, SrcIP: src, DstIP: dst}
+				build(layers.EthernetTypeIPv4, ip, l4(proto, port, peer))
+				// IPv4 header with options, the ports are loaded after them
+				ipOpt := *ip
+				ipOpt.Options = []layers.IPv4Option{{OptionType: 1}, {OptionType: 1}, {OptionType: 1}, {OptionType: 0}}
+				build(layers.EthernetTypeIPv4, &ipOpt, l4(proto, peer, port))
+				// non-first fragment, without ports
+				frag := *ip
+				frag.FragOffset = 10
+				build(layers.EthernetTypeIPv4, &frag, l4(proto, port, peer))
+			}
+		}
+		// ARP & RARP carrying the IPv4 addresses
+		for _, ethType := range []layers.EthernetType{layers.EthernetTypeARP, 0x8035} {
+			build(ethType, &layers.ARP{
+				AddrType:          layers.LinkTypeEthernet,
+				Protocol:          layers.EthernetTypeIPv4,
+				HwAddressSize:     6,
+				ProtAddressSize:   4,
+				Operation:         layers.ARPRequest,
+				SourceHwAddress:   net.HardwareAddr{2, 0, 0, 0, 0, 1},
+				SourceProtAddress: src.To4(),
+				DstHwAddress:      make([]byte, 6),
+				DstProtAddress:    dst.To4(),
+			})
+		}
+	}
+
+	for i, src := range ip6s {
+		dst := ip6s[(i+1)%len(ip6s)]
+		for _, proto := range append(protos, layers.IPProtocolICMPv6) {
+			for j, port := range ports {
+				peer := ports[(j+3)%len(ports)]
+				build(layers.EthernetTypeIPv6,
+					&layers.IPv6{Version: 6, HopLimit: 64, NextHeader: proto, SrcIP: src, DstIP: dst}, l4(proto, port, peer))
+				// first fragment, the protocol is told by the fragment header
+				build(layers.EthernetTypeIPv6,
+					&layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolIPv6Fragment, SrcIP: dst, DstIP: src},
+					gopacket.Payload{byte(proto), 0, 0, 1, 0, 0, 0, 42}, l4(proto, peer, port))
+			}
+		}
+	}
+	return ret
+}
+
+func run(t *testing.T, prog []bpf.RawInstruction, pkt []byte) int {
+	var ins []bpf.Instruction
+	for _, ri := range prog {
+		ins = append(ins, ri.Disassemble())
+	}
+	vm, err := bpf.NewVM(ins)
+	if err != nil {
+		t.Fatalf("invalid program, detail: %s", err)
+	}
+	n, err := vm.Run(pkt)
+	if err != nil {
+		t.Fatalf("failed to run program, detail: %s", err)
+	}
+	return n
+}
+
+func goldenProgram(prog []sockFilter) []bpf.RawInstruction {
+	ret := make([]bpf.RawInstruction, 0, len(prog))
+	for _, sf := range prog {
+		ret = append(ret, bpf.RawInstruction{Op: sf.code, Jt: sf.jt, Jf: sf.jf, K: sf.k})
+	}
+	return ret
+}
+
+func TestCompileGolden(t *testing.T) {
+	packets := map[layers.LinkType][][]byte{
+		layers.LinkTypeEthernet: corpus(true),
+		layers.LinkTypeRaw:      corpus(false),
+	}
+	for _, g := range goldens {
+		prog, err := Compile(g.expr, g.linkType, goldenSnapLen)
+		if err != nil {
+			t.Errorf("failed to compile `%s` for %s, detail: %s", g.expr, g.linkType, err)
+			continue
+		}
+		golden := goldenProgram(g.prog)
+		matched := 0
+		for _, pkt := range packets[g.linkType] {
+			want := run(t, golden, pkt)
+			if got := run(t, prog, pkt); got != want {
+				t.Errorf("`%s` for %s returns %d on packet %x, want %d", g.expr, g.linkType, got, pkt, want)
+			}
+			if want != 0 {
+				matched++
+			}
+		}
+		// the corpus must tell the filter apart from accepting or rejecting every packet
+		if g.expr != "arp" || g.linkType != layers.LinkTypeRaw {
+			if matched == 0 || matched == len(packets[g.linkType]) {
+				t.Errorf("`%s` for %s matches %d of %d packets in corpus", g.expr, g.linkType, matched, len(packets[g.linkType]))
+			}
+		}
+	}
+}
+
+func TestCompileCompound(t *testing.T) {
+	packets := map[layers.LinkType][][]byte{
+		layers.LinkTypeEthernet: corpus(true),
+		layers.LinkTypeRaw:      corpus(false),
+	}
+	for _, c := range compounds {
+		prog, err := Compile(c.expr, c.linkType, goldenSnapLen)
+		if err != nil {
+			t.Errorf("failed to compile `%s` for %s, detail: %s", c.expr, c.linkType, err)
+			continue
+		}
+		matched := 0
+		for _, pkt := range packets[c.linkType] {
+			want := c.ref.match(t, c.linkType, pkt)
+			if got := run(t, prog, pkt) != 0; got != want {
+				t.Errorf("`%s` for %s matches packet %x: %t, want %t", c.expr, c.linkType, pkt, got, want)
+			}
+			if want {
+				matched++
+			}
+		}
+		if matched == 0 || matched == len(packets[c.linkType]) {
+			t.Errorf("`%s` for %s matches %d of %d packets in corpus", c.expr, c.linkType, matched, len(packets[c.linkType]))
+		}
+	}
+}
+
+func TestCompileUnsupportedLinkType(t *testing.T) {
+	for _, lt := range []layers.LinkType{layers.LinkTypeLinuxSLL, layers.LinkTypeIEEE802_11, layers.LinkTypeNull} {
+		if _, err := Compile("ip", lt, goldenSnapLen); err == nil {
+			t.Errorf("link type %s accepted", lt)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package filter compiles filter expressions into classic BPF programs, so that the uninteresting traffic
+// is dropped by kernel before reaching wakizashi's probe. Only a subset of pcap-filter(7) syntax is supported,
+// on ethernet frames and raw IP packets (like those on an IPIP tunnel):
+//
+//	[src|dst] host <ip or hostname>
+//	[src|dst] net <cidr>
+//	[tcp|udp|sctp] [src|dst] port <port>
+//	[tcp|udp|sctp] [src|dst] portrange <port>-<port>
+//	ip, ip6, arp, tcp, udp, sctp, icmp, icmp6
+//	greater <length>, less <length>
+//
+// combined by and (&&), or (||), not (!) and parentheses, like: not port 10080 and not arp.
+// As in pcap-filter(7), and & or are of equal precedence and evaluated from left to right, not binds tighter.
+package filter
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// node is a node of the filter expression's syntax tree
+type node interface{}
+
+type andNode struct{ left, right node }
+
+type orNode struct{ left, right node }
+
+type notNode struct{ operand node }
+
+// primitive is a single test on packet, like host, port, or protocol
+type primitive struct {
+	dir     string // src, dst or empty for both
+	proto   string // protocol qualifier, or the protocol to test for a bare protocol primitive
+	kind    string // host, net, port, portrange, proto, greater, less
+	ips     []net.IP
+	ipnet   *net.IPNet
+	portMin uint16
+	portMax uint16
+	length  uint32
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+// parse parses the filter expression into syntax tree
+func parse(expr string) (node, error) {
+	p := &parser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+	return n, nil
+}
+
+func tokenize(expr string) []string {
+	var ret []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() != 0 {
+			ret = append(ret, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == '(' || c == ')':
+			flush()
+			ret = append(ret, string(c))
+		case c == '!' && !strings.HasPrefix(expr[i:], "!="):
+			flush()
+			ret = append(ret, "!")
+		case strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||"):
+			flush()
+			ret = append(ret, expr[i:i+2])
+			i++
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return ret
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of filter expression")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+// parseExpr parses the primitives combined by and & or, which are of equal precedence and evaluated
+// from left to right as pcap-filter(7) does, so "tcp or udp and port 53" is "(tcp or udp) and port 53"
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "and", "&&":
+			p.pos++
+			right, err := p.parseNot()
+			if err != nil {
+				return nil, err
+			}
+			left = &andNode{left, right}
+		case "or", "||":
+			p.pos++
+			right, err := p.parseNot()
+			if err != nil {
+				return nil, err
+			}
+			left = &orNode{left, right}
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	if p.peek() == "(" {
+		p.pos++
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, err := p.next(); err != nil || tok != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return n, nil
+	}
+	return p.parsePrimitive()
+}
+
+func (p *parser) parsePrimitive() (node, error) {
+	prim := &primitive{}
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case "ip", "ip6", "arp", "tcp", "udp", "sctp", "icmp", "icmp6":
+		prim.proto = tok
+		if !isQualifiable(tok, p.peek()) {
+			prim.kind = "proto"
+			return prim, nil
+		}
+		tok, _ = p.next()
+	}
+	if tok == "src" || tok == "dst" {
+		prim.dir = tok
+		if tok, err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch tok {
+	case "host":
+		return prim, p.parseHost(prim)
+	case "net":
+		return prim, p.parseNet(prim)
+	case "port", "portrange":
+		return prim, p.parsePort(prim, tok)
+	case "greater", "less":
+		if prim.dir != "" || prim.proto != "" {
+			return nil, fmt.Errorf("%s can not be qualified", tok)
+		}
+		return prim, p.parseLength(prim, tok)
+	}
+	return nil, fmt.Errorf("unsupported primitive %q", tok)
+}
+
+// isQualifiable tells if the protocol is a qualifier of the following primitive, like tcp in "tcp port 80"
+func isQualifiable(proto, next string) bool {
+	switch proto {
+	case "ip", "ip6":
+		return next == "src" || next == "dst" || next == "host" || next == "net"
+	case "tcp", "udp", "sctp":
+		return next == "src" || next == "dst" || next == "port" || next == "portrange"
+	}
+	return false
+}
+
+func (p *parser) parseHost(prim *primitive) error {
+	prim.kind = "host"
+	if prim.proto != "" && prim.proto != "ip" && prim.proto != "ip6" {
+		return fmt.Errorf("host can not be qualified by %s", prim.proto)
+	}
+	val, err := p.next()
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(val); ip != nil {
+		prim.ips = []net.IP{ip}
+		return nil
+	}
+	ips, err := net.LookupIP(val)
+	if err != nil {
+		return fmt.Errorf("failed to lookup host %s, detail: %s", val, err)
+	}
+	prim.ips = ips
+	return nil
+}
+
+func (p *parser) parseNet(prim *primitive) error {
+	prim.kind = "net"
+	if prim.proto != "" && prim.proto != "ip" && prim.proto != "ip6" {
+		return fmt.Errorf("net can not be qualified by %s", prim.proto)
+	}
+	val, err := p.next()
+	if err != nil {
+		return err
+	}
+	_, ipnet, err := net.ParseCIDR(val)
+	if err != nil {
+		return fmt.Errorf("invalid net %s, detail: %s", val, err)
+	}
+	prim.ipnet = ipnet
+	return nil
+}
+
+func (p *parser) parsePort(prim *primitive, kind string) error {
+	prim.kind = "port"
+	val, err := p.next()
+	if err != nil {
+		return err
+	}
+	min, max := val, val
+	if kind == "portrange" {
+		bounds := strings.SplitN(val, "-", 2)
+		if len(bounds) != 2 {
+			return fmt.Errorf("invalid port range %s, try this format: [port]-[port]", val)
+		}
+		min, max = bounds[0], bounds[1]
+	}
+	pmin, err := strconv.ParseUint(min, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", min)
+	}
+	pmax, err := strconv.ParseUint(max, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", max)
+	}
+	if pmin > pmax {
+		return fmt.Errorf("invalid port range %s", val)
+	}
+	prim.portMin, prim.portMax = uint16(pmin), uint16(pmax)
+	return nil
+}
+
+func (p *parser) parseLength(prim *primitive, kind string) error {
+	prim.kind = kind
+	val, err := p.next()
+	if err != nil {
+		return err
+	}
+	length, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid length %s", val)
+	}
+	prim.length = uint32(length)
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package identity describes the identity of probe and the workload it runs alongside, reported to center
+// on every transmit stream, so records can be told apart even if the probe's IP is recycled.
+package identity
+
+import (
+	"BlankZhu/wakizashi/pkg/config"
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Load gathers the identity of probe from config, files and envs.
+// If no ID is configured, the one kept in ID file is used, or a new one is generated and kept in it.
+func Load(cfg config.IdentityConfig, dumpDir string) (*entity.ProbeInfo, error) {
+	id := cfg.ID
+	if id == "" {
+		idFile := cfg.IDFile
+		if idFile == "" {
+			idFile = path.Join(dumpDir, constant.ProbeIDFileName)
+		}
+		var err error
+		if id, err = loadOrCreateID(idFile); err != nil {
+			return nil, err
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname, detail: %s", err)
+	}
+
+	// labels from envs override the ones from file, which override the ones from config
+	labels := make(map[string]string)
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	if cfg.LabelsFile != "" {
+		if err := readLabelsFile(cfg.LabelsFile, labels); err != nil {
+			return nil, err
+		}
+	}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, constant.EnvLabelPrefix) {
+			continue
+		}
+		kv := strings.SplitN(strings.TrimPrefix(env, constant.EnvLabelPrefix), "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			labels[kv[0]] = kv[1]
+		}
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	ret := &entity.ProbeInfo{
+		ID:        id,
+		Hostname:  hostname,
+		Node:      os.Getenv(constant.EnvNodeName),
+		Pod:       os.Getenv(constant.EnvPodName),
+		Namespace: os.Getenv(constant.EnvPodNamespace),
+		Labels:    labels,
+	}
+	return ret, nil
+}
+
+// loadOrCreateID reads the ID kept in file, or generates a new one and keeps it in file if absent
+func loadOrCreateID(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err == nil {
+		if id := strings.TrimSpace(string(b)); id != "" {
+			return id, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read probe ID from %s, detail: %s", file, err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory for probe ID file %s, detail: %s", file, err)
+	}
+	if err := ioutil.WriteFile(file, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to keep probe ID in %s, detail: %s", file, err)
+	}
+	return id, nil
+}
+
+// newID generates a random UUID (version 4)
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate probe ID, detail: %s", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// readLabelsFile reads the labels in key="value" lines into labels, in the format of K8S downward API volume
+func readLabelsFile(file string, labels map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open labels file %s, detail: %s", file, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid line in labels file %s: %s", file, line)
+		}
+		value := kv[1]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		labels[kv[0]] = value
+	}
+	return scanner.Err()
+}
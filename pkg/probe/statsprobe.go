@@ -0,0 +1,65 @@
+package probe
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/types"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// StatsProbe serves the capture statistics of network devices in JSON on path: /stats,
+// and the state of reporter's connection to center on path: /reporter
+type StatsProbe struct {
+	Counters []*types.CaptureCounter
+	Reporter *types.ReporterStatus
+	serv     *http.Server
+}
+
+// Start will block the process until the inner server is closed, or return err
+func (sp *StatsProbe) Start(port uint16) error {
+	mux := http.NewServeMux()
+	mux.Handle("/stats", sp)
+	mux.HandleFunc("/reporter", sp.serveReporter)
+
+	sp.serv = &http.Server{
+		Addr:    ":" + strconv.Itoa(int(port)),
+		Handler: mux,
+	}
+	return sp.serv.ListenAndServe()
+}
+
+// Stop will close the stats probe
+func (sp *StatsProbe) Stop() error {
+	return sp.serv.Close()
+}
+
+func (sp *StatsProbe) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	stats := make([]entity.CaptureStats, 0, len(sp.Counters))
+	for _, c := range sp.Counters {
+		stats = append(stats, c.Get())
+	}
+	b, err := json.Marshal(stats)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (sp *StatsProbe) serveReporter(w http.ResponseWriter, req *http.Request) {
+	if sp.Reporter == nil {
+		http.NotFound(w, req)
+		return
+	}
+	b, err := json.Marshal(sp.Reporter.Get())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -1,6 +1,6 @@
 // Package recovery describe the recovery mechanism used by wakizashi for error posting handling.
 // Example:
-//  r := recovery.Get()
+//  r := recovery.New()
 //  err := r.Init(recovFile, posFile, recovLimit, cacheSize, postFunc)
 //  if err != nil {
 //  ...
@@ -35,6 +35,9 @@ import (
 // RecoverPostFunc define the actual re-post behaviour of the recovery
 type RecoverPostFunc func(record *entity.TrafficRecord) error
 
+// RecoverBatchPostFunc define the re-post behaviour of the recovery in batch
+type RecoverBatchPostFunc func(records []*entity.TrafficRecord) error
+
 // DefaultPosLimit the maximun size of pos, implying when to rotate to the next file
 const DefaultPosLimit = (1 << 20) * 10 // currently, 10M
 // DefaultPosFileName filename of position file, saving the offset of recovery file
@@ -44,15 +47,16 @@ const DefaultPosFileName = "pos"
 const DefaultRecoveryFileName = "data"
 
 // FailureRecover help wakizashi
-var failureRecovery *recovery
+var failureRecovery *Recovery
 var newOnce sync.Once
-var initOnce sync.Once
 
-type recovery struct {
+// Recovery spools the records failed to post in file, and reposts them later
+type Recovery struct {
 	recoveryFilePath    string     // recording traffic data
 	positionFilePath    string     // recording the current offset of recovery file
 	recoveryLengthLimit int64      // size limit of recovery data recoding file
 	recoveryMutex       sync.Mutex // mutex of the recovery file
+	repostMutex         sync.Mutex // mutex of reposting in batches
 	positionMutex       sync.Mutex // mutex of the position file
 
 	cache      []*entity.TrafficRecord // cache to hold the incoming traffic record
@@ -60,21 +64,27 @@ type recovery struct {
 	cacheMutex sync.Mutex
 	recordChan chan *entity.TrafficRecord // cache channel for incoming writing
 	postFunc   RecoverPostFunc            // function used for posting to data storage backend
+	initOnce   sync.Once
 }
 
 // Get return the singleton recovery
-func Get() *recovery {
+func Get() *Recovery {
 	newOnce.Do(func() {
-		failureRecovery = &recovery{}
+		failureRecovery = New()
 	})
 	return failureRecovery
 }
 
+// New return a recovery independent of others, it should be initialized with its own files
+func New() *Recovery {
+	return &Recovery{}
+}
+
 // Init initialize the recovery and launch it, and only the first call will do the job
-func (r *recovery) Init(recoveryFilePath, positionFilePath string, recoveryLengthLimit int64, cacheSize int, pfunc RecoverPostFunc) error {
+func (r *Recovery) Init(recoveryFilePath, positionFilePath string, recoveryLengthLimit int64, cacheSize int, pfunc RecoverPostFunc) error {
 	var retErr error = nil
 
-	initOnce.Do(func() {
+	r.initOnce.Do(func() {
 		r.recordChan = make(chan *entity.TrafficRecord, cacheSize)
 
 		r.recoveryMutex.Lock()
@@ -122,8 +132,9 @@ func (r *recovery) Init(recoveryFilePath, positionFilePath string, recoveryLengt
 	return retErr
 }
 
-// RepostRecord read recovery file, then do the recovery reposting
-func (r *recovery) RepostRecord() {
+// RepostRecord read recovery file, then do the recovery reposting. It stops at the first record failed to post,
+// which is reposted again next time, as the data storage backend is likely still unavailable
+func (r *Recovery) RepostRecord() {
 	r.recoveryMutex.Lock()
 	defer r.recoveryMutex.Unlock()
 
@@ -143,52 +154,108 @@ func (r *recovery) RepostRecord() {
 		return
 	}
 	// read history records
-	failedRecords := make([]entity.TrafficRecord, 0, r.cacheSize)
 	lineReader := bufio.NewScanner(rf)
 	for lineReader.Scan() {
 		var record entity.TrafficRecord
 		line := lineReader.Text()
-		pos += int64(len(line) + 1)
 
 		err := json.Unmarshal([]byte(line), &record)
 		if err != nil {
+			pos += int64(len(line) + 1)
 			continue
 		}
 
 		err = r.post(&record)
 		if err != nil {
-			logrus.Warningf("Failed to post record, detail: %d", err)
-			failedRecords = append(failedRecords, record)
+			logrus.Warningf("Failed to post record, will retry later, detail: %s", err)
+			break
 		}
+		pos += int64(len(line) + 1)
 	}
 
-	// check if current pos is out of PosLimit
-	if pos > r.recoveryLengthLimit {
-		pos = r.clearRecord(pos)
-	}
+	r.savePosition(pos)
+}
 
-	for _, record := range failedRecords {
-		str, err := record.ToJSONString()
+// RepostBatch works like RepostRecord, but reposts the records in batches of at most batchSize by pfunc.
+// It stops at the first batch failed to post, which is reposted again next time. The recovery file is locked
+// only while a batch is read, so the records are spooled while a slow data backend is being posted to
+func (r *Recovery) RepostBatch(batchSize int, pfunc RecoverBatchPostFunc) {
+	r.repostMutex.Lock()
+	defer r.repostMutex.Unlock()
+
+	pos := r.getPosition()
+	for {
+		batch, end, eof, err := r.readBatch(pos, batchSize)
 		if err != nil {
-			logrus.Warningf("Failed to parse to JSON string: %v", record)
-			continue
+			logrus.Errorf("Failed to read recovery file, detail: %s", err)
+			return
 		}
-		_, err = rf.WriteString(str + "\n")
-		if err != nil {
-			logrus.Errorf("Failed to write recovery file, with reocrd: %s", str)
-			continue
+		if len(batch) != 0 {
+			if err := pfunc(batch); err != nil {
+				logrus.Warningf("Failed to post %d records, will retry later, detail: %s", len(batch), err)
+				return
+			}
+		}
+		if end != pos {
+			r.recoveryMutex.Lock()
+			pos = r.savePosition(end)
+			r.recoveryMutex.Unlock()
+		}
+		if eof {
+			return
 		}
 	}
+}
+
+// readBatch reads at most batchSize records from pos of the recovery file, return the position after the last line
+// read, and whether the end of file is reached
+func (r *Recovery) readBatch(pos int64, batchSize int) ([]*entity.TrafficRecord, int64, bool, error) {
+	r.recoveryMutex.Lock()
+	defer r.recoveryMutex.Unlock()
 
-	err = r.writePosition(pos)
+	rf, err := os.OpenFile(r.recoveryFilePath, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
+		return nil, pos, false, err
+	}
+	defer rf.Close()
+	if _, err = rf.Seek(pos, 0); err != nil {
+		return nil, pos, false, err
+	}
+
+	batch := make([]*entity.TrafficRecord, 0, batchSize)
+	lineReader := bufio.NewScanner(rf)
+	for len(batch) < batchSize {
+		if !lineReader.Scan() {
+			return batch, pos, true, lineReader.Err()
+		}
+		line := lineReader.Text()
+		pos += int64(len(line) + 1)
+		var record entity.TrafficRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			continue
+		}
+		batch = append(batch, &record)
+	}
+	return batch, pos, false, nil
+}
+
+// savePosition saves the position reposted to, and clears the recovery file if it is out of limit and all reposted,
+// return the position saved. It requires recoveryMutex held
+func (r *Recovery) savePosition(pos int64) int64 {
+	if pos > r.recoveryLengthLimit {
+		if fi, err := os.Stat(r.recoveryFilePath); err == nil && pos >= fi.Size() {
+			pos = r.clearRecord(pos)
+		}
+	}
+
+	if err := r.writePosition(pos); err != nil {
 		logrus.Errorf("Failed to write position file, detail: %s", err)
 	}
-	return
+	return pos
 }
 
 // FlushRecords flush all the traffic record in cache to recovery file
-func (r *recovery) FlushRecords() {
+func (r *Recovery) FlushRecords() {
 	if len(r.cache) == 0 {
 		return
 	}
@@ -224,16 +291,45 @@ func (r *recovery) FlushRecords() {
 	}
 }
 
+// Spool writes post-failed records to recovery file directly, unlike Add2Recovery,
+// it returns only after the records are persisted
+func (r *Recovery) Spool(records []*entity.TrafficRecord) error {
+	r.recoveryMutex.Lock()
+	defer r.recoveryMutex.Unlock()
+
+	f, err := os.OpenFile(r.recoveryFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	for _, record := range records {
+		str, err := record.ToJSONString()
+		if err != nil {
+			logrus.Warningf("Failed to parse to JSON string: %v", record)
+			continue
+		}
+		if _, err = w.WriteString(str + "\n"); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
 // Add2Recovery is used to add a post-failed record to recovery by the caller
-func (r *recovery) Add2Recovery(record *entity.TrafficRecord) {
+func (r *Recovery) Add2Recovery(record *entity.TrafficRecord) {
 	r.recordChan <- record
 }
 
-func (r *recovery) post(record *entity.TrafficRecord) error {
+func (r *Recovery) post(record *entity.TrafficRecord) error {
 	return r.postFunc(record)
 }
 
-func (r *recovery) clearRecord(pos int64) int64 {
+func (r *Recovery) clearRecord(pos int64) int64 {
 	err := os.Remove(r.recoveryFilePath)
 	if err != nil {
 		logrus.Errorf("Failed to remove recovery file, detail: %s", err)
@@ -251,7 +347,7 @@ func (r *recovery) clearRecord(pos int64) int64 {
 		logrus.Errorf("Failed to remove position file, detail: %s", err)
 		return 0
 	}
-	pf, err := os.OpenFile(r.recoveryFilePath, os.O_CREATE, 0666)
+	pf, err := os.OpenFile(r.positionFilePath, os.O_CREATE, 0666)
 	if err != nil {
 		logrus.Errorf("Failed to re-create position file, detail: %s", err)
 		return 0
@@ -261,7 +357,7 @@ func (r *recovery) clearRecord(pos int64) int64 {
 	return 0
 }
 
-func (r *recovery) getPosition() int64 {
+func (r *Recovery) getPosition() int64 {
 	r.positionMutex.Lock()
 	defer r.positionMutex.Unlock()
 	var ret int64 = 0
@@ -281,15 +377,16 @@ func (r *recovery) getPosition() int64 {
 	return ret
 }
 
-func (r *recovery) writePosition(pos int64) error {
+func (r *Recovery) writePosition(pos int64) error {
 	r.positionMutex.Lock()
 	defer r.positionMutex.Unlock()
 
 	buf := bytes.NewBufferString(strconv.FormatInt(pos, 10))
-	pf, err := os.OpenFile(r.positionFilePath, os.O_CREATE|os.O_WRONLY, 0666)
+	pf, err := os.OpenFile(r.positionFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
+	defer pf.Close()
 	_, err = pf.Write(buf.Bytes())
 	if err != nil {
 		return err
@@ -0,0 +1,70 @@
+package report
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"sort"
+	"time"
+)
+
+// Aggregator aggregates the raw records into traffic records by their capture time,
+// unlike the reporter's cache, the records are timestamped by the interval they are captured in
+type Aggregator struct {
+	Interval time.Duration       // length of the time bucket, if non-positive, use 1 second
+	ProbeIPs map[string]struct{} // IP set of probe, used to derive the direction of traffic
+	data     map[int64]map[string]*entity.TrafficRecord
+}
+
+// Init initializes the aggregator
+func (a *Aggregator) Init() {
+	if a.Interval <= 0 {
+		a.Interval = time.Second
+	}
+	a.data = make(map[int64]map[string]*entity.TrafficRecord)
+}
+
+// Add aggregates a raw record captured at ts
+func (a *Aggregator) Add(raw *entity.RawTrafficRecord, ts time.Time) {
+	record := toTrafficRecord(raw, a.ProbeIPs)
+	if record == nil {
+		return
+	}
+	record.Timestamp = ts.UTC().Truncate(a.Interval).Unix()
+
+	bucket, ok := a.data[record.Timestamp]
+	if !ok {
+		bucket = make(map[string]*entity.TrafficRecord)
+		a.data[record.Timestamp] = bucket
+	}
+	key := cacheKey(record)
+	if v, ok := bucket[key]; ok {
+		v.Size = v.Size + record.Size
+		v.Packets = v.Packets + record.Packets
+	} else {
+		bucket[key] = record
+	}
+}
+
+// Records return the aggregated records, ordered by timestamp and then the aggregation key
+func (a *Aggregator) Records() []*entity.TrafficRecord {
+	timestamps := make([]int64, 0, len(a.data))
+	for ts := range a.data {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+
+	ret := make([]*entity.TrafficRecord, 0)
+	for _, ts := range timestamps {
+		bucket := a.data[ts]
+		keys := make([]string, 0, len(bucket))
+		for key := range bucket {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			ret = append(ret, bucket[key])
+		}
+	}
+	return ret
+}
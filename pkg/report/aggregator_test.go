@@ -0,0 +1,134 @@
+package report
+
+import (
+	"BlankZhu/wakizashi/pkg/dump"
+	"BlankZhu/wakizashi/pkg/entity"
+	"path"
+	"testing"
+	"time"
+)
+
+// epoch of the fixtures in pkg/dump/testdata, 2021-03-01 00:00:00 UTC
+const fixtureEpoch int64 = 1614556800
+
+func ipSet(ips ...string) map[string]struct{} {
+	ret := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		ret[ip] = struct{}{}
+	}
+	return ret
+}
+
+func TestAggregateReplay(t *testing.T) {
+	probeIPs := ipSet("10.0.0.1", "10.0.0.3", "fd00::1")
+	centerIPs := ipSet("10.0.0.100", "fd00::100")
+
+	tests := []struct {
+		name      string
+		file      string
+		interval  time.Duration
+		probeIPs  map[string]struct{}
+		centerIPs map[string]struct{}
+		want      []entity.TrafficRecord
+	}{
+		{
+			name:      "ipv4 by second",
+			file:      "ipv4.pcap",
+			interval:  time.Second,
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []entity.TrafficRecord{
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 60, Protocol: "icmp", Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 1054, Protocol: "tcp", SrcPort: 80, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 62, Protocol: "udp", DstPort: 53, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 214, Protocol: "tcp", DstPort: 80, Direction: "ingress", Packets: 2},
+				{Timestamp: fixtureEpoch + 1, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.3", Size: 60, Protocol: "tcp", DstPort: 8080, Direction: "local", Packets: 1},
+				{Timestamp: fixtureEpoch + 1, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 60, Protocol: "sctp", SrcPort: 5000, DstPort: 3868, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch + 1, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 60, Protocol: "tcp", DstPort: 80, Direction: "ingress", Packets: 1},
+			},
+		},
+		{
+			name:      "ipv4 in one bucket",
+			file:      "ipv4.pcap",
+			interval:  time.Minute,
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []entity.TrafficRecord{
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 60, Protocol: "icmp", Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 1054, Protocol: "tcp", SrcPort: 80, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 62, Protocol: "udp", DstPort: 53, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.3", Size: 60, Protocol: "tcp", DstPort: 8080, Direction: "local", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 60, Protocol: "sctp", SrcPort: 5000, DstPort: 3868, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 274, Protocol: "tcp", DstPort: 80, Direction: "ingress", Packets: 3},
+			},
+		},
+		{
+			name:     "traffic with center aggregated without center IPs",
+			file:     "ipv4.pcap",
+			interval: time.Minute,
+			probeIPs: ipSet("10.0.0.1"),
+			want: []entity.TrafficRecord{
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.100", DstIP: "10.0.0.1", Size: 64, Protocol: "tcp", SrcPort: 10080, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.100", Size: 64, Protocol: "tcp", DstPort: 10080, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 60, Protocol: "icmp", Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 1054, Protocol: "tcp", SrcPort: 80, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Size: 62, Protocol: "udp", DstPort: 53, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.1", DstIP: "10.0.0.3", Size: 60, Protocol: "tcp", DstPort: 8080, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 60, Protocol: "sctp", SrcPort: 5000, DstPort: 3868, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 274, Protocol: "tcp", DstPort: 80, Direction: "ingress", Packets: 3},
+			},
+		},
+		{
+			name:      "ipv6 with extension headers",
+			file:      "ipv6.pcapng",
+			interval:  time.Second,
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []entity.TrafficRecord{
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::1", DstIP: "fd00::2", Size: 62, Protocol: "icmpv6", Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::1", DstIP: "fd00::2", Size: 190, Protocol: "tcp", SrcPort: 443, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::1", DstIP: "fd00::2", Size: 90, Protocol: "udp", DstPort: 53, Direction: "egress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::1", Size: 74, Protocol: "tcp", DstPort: 443, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::2", DstIP: "fd00::1", Size: 170, Protocol: "udp", DstPort: 514, Direction: "ingress", Packets: 1},
+			},
+		},
+		{
+			name:      "raw ip",
+			file:      "raw.pcap",
+			interval:  time.Second,
+			probeIPs:  probeIPs,
+			centerIPs: centerIPs,
+			want: []entity.TrafficRecord{
+				{Timestamp: fixtureEpoch, ProbeIP: "10.0.0.1", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", Size: 40, Protocol: "tcp", DstPort: 80, Direction: "ingress", Packets: 1},
+				{Timestamp: fixtureEpoch, ProbeIP: "fd00::1", SrcIP: "fd00::1", DstIP: "fd00::2", Size: 68, Protocol: "udp", DstPort: 53, Direction: "egress", Packets: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := &Aggregator{Interval: tt.interval, ProbeIPs: tt.probeIPs}
+			agg.Init()
+			dec := &dump.Decoder{
+				ProbeIPs:         tt.probeIPs,
+				CenterIPs:        tt.centerIPs,
+				EphemeralPortMin: 32768,
+			}
+			if err := dump.Replay(path.Join("..", "dump", "testdata", tt.file), dec, agg.Add); err != nil {
+				t.Fatalf("failed to replay %s: %s", tt.file, err)
+			}
+
+			got := agg.Records()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.want))
+			}
+			for i, record := range got {
+				gotStr, _ := record.ToJSONString()
+				wantStr, _ := tt.want[i].ToJSONString()
+				if gotStr != wantStr {
+					t.Errorf("record %d mismatch\ngot:  %s\nwant: %s", i, gotStr, wantStr)
+				}
+			}
+		})
+	}
+}
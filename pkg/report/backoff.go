@@ -0,0 +1,33 @@
+package report
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delays between attempts to reconnect to center, growing exponentially up to Max,
+// each randomized by Jitter so that probes cut off by the same center restart don't reconnect all at once
+type Backoff struct {
+	Min    time.Duration // delay before the first retry
+	Max    time.Duration // upper bound of delays
+	Jitter float64       // fraction of delay randomized in both directions, in [0, 1]
+	Rand   *rand.Rand    // source of the jitter, not shared with other goroutines, seeded by Reporter.Init if not set
+}
+
+// Delay return the delay before the retry after given count of consecutive failures
+func (b Backoff) Delay(failures int) time.Duration {
+	delay := b.Min
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	if b.Jitter > 0 {
+		delay += time.Duration((b.Rand.Float64()*2 - 1) * b.Jitter * float64(delay))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
@@ -2,51 +2,148 @@ package report
 
 import (
 	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/discovery"
 	"BlankZhu/wakizashi/pkg/entity"
 	"BlankZhu/wakizashi/pkg/transmit"
+	"BlankZhu/wakizashi/pkg/transmit/message"
 	"BlankZhu/wakizashi/pkg/types"
 	"BlankZhu/wakizashi/pkg/util"
 	"bufio"
 	"context"
+	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
 )
 
 // Reporter get send the traffic data to the data backend
 type Reporter struct {
-	AutoClear   bool            // clear the processed dump file or not
-	DumpDir     string          // directory to save dump file
-	FileCh      <-chan string   // channel used to communicate between reporter & dumper
-	Ifaces      []net.Interface // on which network interface the reporter is working
-	RepAddr     string          // address of the center
-	RepRetry    int             // retry count to transmit data to center
-	RepInterval time.Duration   // retry interval
-	repCache    types.ReporterCache
-	transCli    transmit.TransmitClient
+	AutoClear    bool                             // clear the processed dump file or not
+	DumpDir      string                           // directory to save dump file
+	FileCh       <-chan string                    // channel used to communicate between reporter & dumper
+	RecordCh     <-chan *entity.RawTrafficRecord  // if set, records are aggregated from dumper in memory instead of FileCh
+	MaxCacheSize int                              // maximum count of cached records, if non-positive, unlimited
+	Ifaces       []net.Interface                  // on which network interface the reporter is working
+	Centers      *discovery.Centers               // centers to report to, balanced in round robin
+	RepRetry     int                              // count of consecutive failures to give up reporting to center, if 0, never give up
+	RepDeadline  time.Duration                    // give up if center is unreachable for this long, if 0, never give up
+	RepBackoff   Backoff                          // delays between retries, defaults are used by Init if not set
+	RepInterval  time.Duration                    // interval of transmitting the cache to center, at least ProbeMinReportInterval
+	Counters     []*types.CaptureCounter          // capture statistics reported to center along with the records
+	Creds        credentials.TransportCredentials // if set, connect to center with the credentials, otherwise in plaintext
+	Token        credentials.PerRPCCredentials    // if set, present the token to center on every call
+	Info         *entity.ProbeInfo                // if set, identify the probe to center on every stream
+	Status       *types.ReporterStatus            // state of the connection to center, created by Init if not set
+	repCache     types.ReporterCache
+	transCli     transmit.TransmitClient
+	seq          uint64                   // sequence number of the last batch
+	pending      map[uint64]*pendingBatch // batches waiting for center's acknowledgement
+	pendingMtx   sync.Mutex
+	connected    int32                           // 1 if transmit stream to center is established, accessed atomically
+	flushCh      chan struct{}                   // signal to transmit the cache before next tick
+	spillCnt     uint64                          // count of spill files generated, used to name the spill file
+	consumer     func(ctx context.Context) error // consumes the cache over a stream to center, set to consume by Init
+	resetAfter   time.Duration                   // a stream lasting this long resets the backoff
+}
+
+// pendingBatch is a batch transmitted (or to be transmitted) but not acknowledged by center yet
+type pendingBatch struct {
+	batch    *message.TransmitBatch
+	inflight bool // sent on current stream, waiting for acknowledgement
 }
 
 // Init initialize the traffic reporter
 func (r *Reporter) Init() {
 	r.repCache.Init()
+	r.pending = make(map[uint64]*pendingBatch)
+	r.flushCh = make(chan struct{}, 1)
+	if r.Status == nil {
+		r.Status = types.NewReporterStatus()
+	}
+	if r.RepInterval < constant.ProbeMinReportInterval*time.Second {
+		r.RepInterval = constant.ProbeMinReportInterval * time.Second
+	}
+	if r.RepBackoff.Min <= 0 {
+		r.RepBackoff.Min = constant.ProbeDefaultBackoffMin * time.Second
+	}
+	if r.RepBackoff.Max <= 0 {
+		r.RepBackoff.Max = constant.ProbeDefaultBackoffMax * time.Second
+	}
+	if r.RepBackoff.Max < r.RepBackoff.Min {
+		r.RepBackoff.Max = r.RepBackoff.Min
+	}
+	if r.RepBackoff.Jitter == 0 {
+		r.RepBackoff.Jitter = constant.ProbeBackoffJitter
+	}
+	if r.RepBackoff.Rand == nil {
+		r.RepBackoff.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	r.consumer = r.consume
+	r.resetAfter = constant.ProbeBackoffResetDuration * time.Second
 }
 
-// Start starts the reporter process
-func (r *Reporter) Start() {
-	// todo
+// Start starts the reporter process, it blocks until ctx is done or the reporter gives up
+// reporting to the center, in which case a non-nil error is returned.
+// The records not acknowledged by center are spilled to disk before return, and loaded on next start.
+func (r *Reporter) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if r.RecordCh != nil {
+		go r.handleRecord(ctx)
+	} else {
+		go r.handleCapturedFile(ctx)
+	}
+	err := r.report(ctx)
+	cancel()
+	r.spillUnsent()
+	return err
 }
 
-func (r *Reporter) handleCapturedFile() {
+// handleRecord aggregates the records from dumper into cache directly
+func (r *Reporter) handleRecord(ctx context.Context) {
+	ips := util.GetIPSetFromNetworkInterfaces(r.Ifaces)
 	for {
 		select {
-		case filename := <-r.FileCh:
+		case <-ctx.Done():
+			return
+		case raw, ok := <-r.RecordCh:
+			if !ok {
+				logrus.Warnf("record channel closed, stop handling records")
+				return
+			}
+			record := toTrafficRecord(raw, ips)
+			if record == nil {
+				continue
+			}
+			r.loadCache([]*entity.TrafficRecord{record})
+		}
+	}
+}
+
+func (r *Reporter) handleCapturedFile(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case filename, ok := <-r.FileCh:
+			if !ok {
+				logrus.Warnf("captured file channel closed, stop handling captured files")
+				return
+			}
 			logrus.Infof("processing captured traffic recording file: %s", filename)
 			records := r.analyzeCapturedFile(filename)
 			r.loadCache(records)
@@ -73,40 +170,21 @@ func (r *Reporter) analyzeCapturedFile(filename string) []*entity.TrafficRecord
 	}
 	defer f.Close()
 
+	ips := util.GetIPSetFromNetworkInterfaces(r.Ifaces)
 	sc := bufio.NewScanner(f)
 	for sc.Scan() {
 		line := sc.Text()
-		elems := strings.Split(line, " ")
-		if len(elems) != 3 {
-			logrus.Warnf("get invalid line in dump file %s, with: %s", filepath, line)
-			continue
-		}
-
-		srcIP := elems[0]
-		dstIP := elems[1]
-		sz, err := strconv.ParseUint(elems[2], 10, 64)
+		raw, err := entity.ParseRawTrafficRecord(line)
 		if err != nil {
-			logrus.Warnf("failed to extract size in dump file %s, detail; %s", filepath, line)
+			logrus.Warnf("get invalid line in dump file %s, with: %s, detail: %s", filepath, line, err)
 			continue
 		}
 
-		var probeIP string
-		ips := util.GetIPSetFromNetworkInterfaces(r.Ifaces)
-		if _, b := ips[srcIP]; b {
-			probeIP = srcIP
-		} else if _, b := ips[dstIP]; b {
-			probeIP = dstIP
-		} else {
+		record := toTrafficRecord(raw, ips)
+		if record == nil {
 			continue
 		}
-
-		r := entity.TrafficRecord{
-			SrcIP:   srcIP,
-			DstIP:   dstIP,
-			Size:    sz,
-			ProbeIP: probeIP,
-		}
-		ret = append(ret, &r)
+		ret = append(ret, record)
 	}
 	if sc.Err() != nil {
 		logrus.Errorf("failed to scan dump file %s, detail: %s", filepath, sc.Err())
@@ -115,86 +193,408 @@ func (r *Reporter) analyzeCapturedFile(filename string) []*entity.TrafficRecord
 	return ret
 }
 
+// toTrafficRecord convert the raw record of a single packet to traffic record,
+// with direction derived from the probe's IP set, return nil if the traffic is not related to probe
+func toTrafficRecord(raw *entity.RawTrafficRecord, probeIPs map[string]struct{}) *entity.TrafficRecord {
+	_, isFromProbe := probeIPs[raw.SrcIP]
+	_, isToProbe := probeIPs[raw.DstIP]
+
+	var probeIP, direction string
+	switch {
+	case isFromProbe && isToProbe:
+		probeIP, direction = raw.SrcIP, constant.DirectionLocal
+	case isFromProbe:
+		probeIP, direction = raw.SrcIP, constant.DirectionEgress
+	case isToProbe:
+		probeIP, direction = raw.DstIP, constant.DirectionIngress
+	default:
+		return nil
+	}
+
+	return &entity.TrafficRecord{
+		SrcIP:     raw.SrcIP,
+		DstIP:     raw.DstIP,
+		Size:      raw.Size,
+		ProbeIP:   probeIP,
+		Protocol:  raw.Protocol,
+		SrcPort:   raw.SrcPort,
+		DstPort:   raw.DstPort,
+		Direction: direction,
+		Packets:   1,
+	}
+}
+
 func (r *Reporter) loadCache(records []*entity.TrafficRecord) {
 	ts := time.Now().UTC().Unix()
+	for _, record := range records {
+		record.Timestamp = ts
+	}
 	r.repCache.Lock()
-	defer r.repCache.Unlock()
+	r.mergeCache(records)
+	r.repCache.Unlock()
+	r.checkCacheSize()
+}
 
-	var kb strings.Builder
+// mergeCache aggregates the records into cache, the records new to cache keep their timestamp, requires repCache locked
+func (r *Reporter) mergeCache(records []*entity.TrafficRecord) {
 	for _, record := range records {
-		kb.WriteString(record.SrcIP)
-		kb.WriteString("_")
-		kb.WriteString(record.DstIP)
-		key := kb.String()
-		kb.Reset()
+		key := cacheKey(record)
 		_, b := r.repCache.Data[key]
 		if b {
 			r.repCache.Data[key].Size = r.repCache.Data[key].Size + record.Size
+			r.repCache.Data[key].Packets = r.repCache.Data[key].Packets + record.Packets
 		} else {
 			r.repCache.Data[key] = record
-			r.repCache.Data[key].Timestamp = ts
 		}
 	}
 }
 
-func (r *Reporter) consume() {
-	conn, err := grpc.Dial(r.RepAddr, grpc.WithInsecure(), grpc.WithTimeout(time.Second*constant.ProbeTransmitTimeout))
+// cacheKey return the key to aggregate the record in cache
+func cacheKey(record *entity.TrafficRecord) string {
+	var kb strings.Builder
+	kb.WriteString(record.SrcIP)
+	kb.WriteString("_")
+	kb.WriteString(record.DstIP)
+	kb.WriteString("_")
+	kb.WriteString(record.Protocol)
+	kb.WriteString("_")
+	kb.WriteString(strconv.Itoa(int(record.SrcPort)))
+	kb.WriteString("_")
+	kb.WriteString(strconv.Itoa(int(record.DstPort)))
+	return kb.String()
+}
+
+func (r *Reporter) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, time.Second*constant.ProbeTransmitTimeout)
+	defer cancel()
+	security := grpc.WithInsecure()
+	if r.Creds != nil {
+		security = grpc.WithTransportCredentials(r.Creds)
+	}
+	// connections to every center endpoint are retried by grpc with the same backoff as reporter
+	connParams := grpc.ConnectParams{
+		Backoff: backoff.Config{
+			BaseDelay:  r.RepBackoff.Min,
+			Multiplier: 2,
+			Jitter:     r.RepBackoff.Jitter,
+			MaxDelay:   r.RepBackoff.Max,
+		},
+		MinConnectTimeout: constant.ProbeConnectTimeout * time.Second,
+	}
+	opts := append(r.Centers.DialOptions(), security, grpc.WithBlock(), grpc.WithConnectParams(connParams))
+	if r.Token != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(r.Token))
+	}
+	conn, err := grpc.DialContext(dialCtx, r.Centers.DialTarget(), opts...)
 	if err != nil {
-		logrus.Errorf("failed to connect to center, detail: %s", err)
-		return
+		return nil, fmt.Errorf("failed to connect to center, detail: %s", err)
+	}
+	return conn, nil
+}
+
+func (r *Reporter) consume(ctx context.Context) error {
+	r.Status.SetConnecting()
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
 	}
 	defer conn.Close()
 	r.transCli = transmit.NewTransmitClient(conn)
-	stream, err := r.transCli.Transmit(context.TODO())
+	stream, err := r.transCli.TransmitBatch(ctx)
 	if err != nil {
-		logrus.Errorf("failed to create transmit stream, detail: %s", err)
-		return
+		return fmt.Errorf("failed to create transmit stream, detail: %s", err)
 	}
+	if err := r.handshake(stream); err != nil {
+		return err
+	}
+
+	// batches sent on previous stream are never acknowledged, retransmit them
+	r.resetInflight()
+	atomic.StoreInt32(&r.connected, 1)
+	defer atomic.StoreInt32(&r.connected, 0)
+	r.Status.SetConnected()
+	logrus.Infof("reporter connected to center")
+	r.loadSpilled()
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			r.acknowledge(ack)
+		}
+	}()
 
 	ticker := time.NewTicker(r.RepInterval)
+	defer ticker.Stop()
 	for {
-		go func(mtx *sync.RWMutex) {
-			mtx.Lock()
-			defer mtx.Unlock()
-			for k, v := range r.repCache.Data {
-				err := stream.Send(&transmit.TransmitRequest{
-					Timestamp: uint64(v.Timestamp), // FIXME: potential casting error here
-					SrcIP:     v.SrcIP,
-					DstIP:     v.DstIP,
-					Size:      v.Size,
-					PodIP:     v.ProbeIP,
-				})
-				if err != nil {
-					logrus.Warnf("failed to transmit record to center, detail: %s", err)
-					continue
-				}
-				delete(r.repCache.Data, k)
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			if err == io.EOF {
+				return fmt.Errorf("transmit stream closed by center")
 			}
-		}(&r.repCache.RWMutex)
-		<-ticker.C
+			return fmt.Errorf("failed to receive acknowledgement from center, detail: %s", err)
+		case <-ticker.C:
+			r.batchCache()
+			if err := r.transmitPending(stream); err != nil {
+				return fmt.Errorf("failed to transmit batch to center, detail: %s", err)
+			}
+			if err := r.transmitStats(stream); err != nil {
+				return fmt.Errorf("failed to transmit capture statistics to center, detail: %s", err)
+			}
+			r.loadSpilled()
+		case <-r.flushCh:
+			r.batchCache()
+			if err := r.transmitPending(stream); err != nil {
+				return fmt.Errorf("failed to transmit batch to center, detail: %s", err)
+			}
+		}
 	}
 }
 
-func (r *Reporter) report() {
+// Upload transmits the records to center once and returns after all of them are acknowledged,
+// it is used to report the records not captured alive, like the ones replayed from pcap file
+func (r *Reporter) Upload(ctx context.Context, records []*entity.TrafficRecord) error {
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stream, err := transmit.NewTransmitClient(conn).TransmitBatch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transmit stream, detail: %s", err)
+	}
+	if err := r.handshake(stream); err != nil {
+		return err
+	}
+
+	r.resetInflight()
+	for {
+		// keep the pending batches bounded, so that nothing is spilled
+		for len(records) != 0 && r.pendingCount() < constant.ProbeMaxPendingBatches/2 {
+			end := constant.ProbeTransmitBatchSize
+			if end > len(records) {
+				end = len(records)
+			}
+			r.addPending(records[:end])
+			records = records[end:]
+		}
+		if len(records) == 0 && r.pendingCount() == 0 {
+			return stream.CloseSend()
+		}
+
+		if err := r.transmitPending(stream); err != nil {
+			return fmt.Errorf("failed to transmit batch to center, detail: %s", err)
+		}
+		ack, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("failed to receive acknowledgement from center, detail: %s", err)
+		}
+		r.acknowledge(ack)
+	}
+}
+
+// handshake identifies the probe to center on a new stream, the batch carries no record so its seq is 0
+func (r *Reporter) handshake(stream transmit.Transmit_TransmitBatchClient) error {
+	if r.Info == nil {
+		return nil
+	}
+	if err := stream.Send(&message.TransmitBatch{Probe: message.NewProbeInfo(r.Info)}); err != nil {
+		return fmt.Errorf("failed to identify probe to center, detail: %s", err)
+	}
+	return nil
+}
+
+// batchCache moves all the cached records into pending batches
+func (r *Reporter) batchCache() {
+	r.repCache.Lock()
+	records := make([]*entity.TrafficRecord, 0, len(r.repCache.Data))
+	for k, v := range r.repCache.Data {
+		records = append(records, v)
+		delete(r.repCache.Data, k)
+	}
+	r.repCache.Unlock()
+
+	r.addPending(records)
+}
+
+// addPending splits records into batches waiting for transmitting,
+// the oldest batches are spilled to disk if center is not acknowledging for too long.
+// The overflowing batches are taken out before spilling, so the file I/O never blocks the acknowledgements.
+func (r *Reporter) addPending(records []*entity.TrafficRecord) {
+	spilled := r.pushPending(records)
+	if len(spilled) == 0 {
+		return
+	}
+	logrus.Warnf("too many unacknowledged batches, spilling %d records to disk", len(spilled))
+	if err := r.spill(spilled); err != nil {
+		logrus.Errorf("failed to spill records, %d records dropped, detail: %s", len(spilled), err)
+	}
+}
+
+// pushPending splits records into pending batches, and takes out the records of the oldest batches
+// over ProbeMaxPendingBatches
+func (r *Reporter) pushPending(records []*entity.TrafficRecord) []*entity.TrafficRecord {
+	r.pendingMtx.Lock()
+	defer r.pendingMtx.Unlock()
+	for start := 0; start < len(records); start += constant.ProbeTransmitBatchSize {
+		end := start + constant.ProbeTransmitBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		reqs := make([]*message.TransmitRequest, 0, end-start)
+		for _, record := range records[start:end] {
+			reqs = append(reqs, message.NewTransmitRequest(record))
+		}
+		r.seq++
+		r.pending[r.seq] = &pendingBatch{
+			batch: &message.TransmitBatch{
+				Seq:     r.seq,
+				Records: reqs,
+			},
+		}
+	}
+
+	if len(r.pending) <= constant.ProbeMaxPendingBatches {
+		return nil
+	}
+	seqs := make([]uint64, 0, len(r.pending))
+	for seq := range r.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] < seqs[j]
+	})
+	spilled := make([]*entity.TrafficRecord, 0)
+	for _, seq := range seqs[:len(seqs)-constant.ProbeMaxPendingBatches] {
+		for _, req := range r.pending[seq].batch.Records {
+			spilled = append(spilled, req.ToTrafficRecord())
+		}
+		delete(r.pending, seq)
+	}
+	return spilled
+}
+
+// transmitPending sends all the pending batches not inflight, in sequence order
+func (r *Reporter) transmitPending(stream transmit.Transmit_TransmitBatchClient) error {
+	r.pendingMtx.Lock()
+	batches := make([]*message.TransmitBatch, 0, len(r.pending))
+	for _, pb := range r.pending {
+		if !pb.inflight {
+			pb.inflight = true
+			batches = append(batches, pb.batch)
+		}
+	}
+	r.pendingMtx.Unlock()
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].Seq < batches[j].Seq
+	})
+	for _, batch := range batches {
+		if err := stream.Send(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transmitStats sends the capture statistics in a batch of seq 0, which needs no retransmission
+// since the statistics are accumulated
+func (r *Reporter) transmitStats(stream transmit.Transmit_TransmitBatchClient) error {
+	if len(r.Counters) == 0 {
+		return nil
+	}
+	stats := make([]*message.CaptureStats, 0, len(r.Counters))
+	for _, c := range r.Counters {
+		stats = append(stats, message.NewCaptureStats(c.Get()))
+	}
+	return stream.Send(&message.TransmitBatch{Stats: stats})
+}
+
+// acknowledge removes the acknowledged batch from pending, or marks it for retransmission on failure
+func (r *Reporter) acknowledge(ack *message.TransmitAck) {
+	r.pendingMtx.Lock()
+	defer r.pendingMtx.Unlock()
+	pb, ok := r.pending[ack.Seq]
+	if !ok {
+		return
+	}
+	if ack.Res {
+		delete(r.pending, ack.Seq)
+		return
+	}
+	logrus.Warnf("center failed to persist batch %d, will retransmit it, detail: %s", ack.Seq, ack.Detail)
+	pb.inflight = false
+}
+
+func (r *Reporter) pendingCount() int {
+	r.pendingMtx.Lock()
+	defer r.pendingMtx.Unlock()
+	return len(r.pending)
+}
+
+func (r *Reporter) resetInflight() {
+	r.pendingMtx.Lock()
+	defer r.pendingMtx.Unlock()
+	for _, pb := range r.pending {
+		pb.inflight = false
+	}
+}
+
+// report keeps consuming the cache until ctx is done, reconnecting to center with backoff once the stream breaks.
+// A stream lasting over ProbeBackoffResetDuration resets the backoff, and the reporter gives up with error after
+// RepRetry consecutive failures, or once center is unreachable over RepDeadline since the last stream connected.
+// The failures are counted here only, the status is told the count along with each backoff.
+func (r *Reporter) report(ctx context.Context) error {
 	failCnt := 0
-	retryFactor := 2
+	failSince := time.Now()
 
 	for {
-		if r.RepRetry == 0 {
-			r.consume()
-			logrus.Warnf("reporter will try consuming cache after %d sec", retryFactor)
-			time.Sleep(time.Second * time.Duration(retryFactor))
-			continue
+		err := r.consumer(ctx)
+		if ctx.Err() != nil {
+			r.Status.SetStopped(nil)
+			return nil
 		}
+		if err == nil {
+			err = fmt.Errorf("transmit stream ended")
+		}
+		logrus.Warnf("reporter stopped consuming cache, detail: %s", err)
 
-		if failCnt < r.RepRetry {
-			r.consume()
-			logrus.Warnf("reporter will try consuming cache after %s sec", retryFactor)
-			failCnt++
-			retryFactor = retryFactor * retryFactor
-		} else {
-			logrus.Errorf("failed to establish connection to center")
-			return
+		now := time.Now()
+		if st := r.Status.Get(); st.ConnectedSince != 0 {
+			// center was reachable until the stream broke
+			failSince = now
+			if now.Sub(time.Unix(st.ConnectedSince, 0)) >= r.resetAfter {
+				failCnt = 0
+			}
+		}
+		failCnt++
+
+		if r.RepRetry != 0 && failCnt > r.RepRetry {
+			err = fmt.Errorf("failed to report to center after %d retries, detail: %s", r.RepRetry, err)
+			r.Status.SetStopped(err)
+			return err
+		}
+		if r.RepDeadline != 0 && now.Sub(failSince) >= r.RepDeadline {
+			err = fmt.Errorf("failed to report to center for %s, detail: %s", now.Sub(failSince).Round(time.Second), err)
+			r.Status.SetStopped(err)
+			return err
+		}
+
+		delay := r.RepBackoff.Delay(failCnt)
+		r.Status.SetBackoff(err, failCnt, now.Add(delay))
+		logrus.Warnf("reporter will try consuming cache after %s", delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.Status.SetStopped(nil)
+			return nil
+		case <-timer.C:
 		}
 	}
 }
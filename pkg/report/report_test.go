@@ -0,0 +1,120 @@
+package report
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReporterInitInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "not set", interval: 0, want: time.Second},
+		{name: "half of minimum capture interval", interval: 500 * time.Millisecond, want: time.Second},
+		{name: "negative", interval: -time.Second, want: time.Second},
+		{name: "kept", interval: 5 * time.Second, want: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reporter{RepInterval: tt.interval}
+			r.Init()
+			if r.RepInterval != tt.want {
+				t.Errorf("report interval %s, want %s", r.RepInterval, tt.want)
+			}
+		})
+	}
+}
+
+// newTestReporter creates a reporter whose streams to center are replaced by consume, retrying almost at once
+func newTestReporter(consume func(r *Reporter) error) (*Reporter, *int) {
+	r := &Reporter{RepBackoff: Backoff{Min: time.Millisecond, Max: time.Millisecond}}
+	r.Init()
+	calls := 0
+	r.consumer = func(ctx context.Context) error {
+		calls++
+		r.Status.SetConnecting()
+		return consume(r)
+	}
+	return r, &calls
+}
+
+func TestReporterReportGiveUp(t *testing.T) {
+	unreachable := func(r *Reporter) error { return errors.New("center unreachable") }
+	tests := []struct {
+		name      string
+		retry     int
+		deadline  time.Duration
+		wantCalls int // exact count of attempts if giving up after retries, otherwise the minimum
+	}{
+		{name: "after retries", retry: 3, wantCalls: 4},
+		{name: "after deadline", deadline: 50 * time.Millisecond, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, calls := newTestReporter(unreachable)
+			r.RepRetry = tt.retry
+			r.RepDeadline = tt.deadline
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := r.report(ctx); err == nil {
+				t.Fatal("reporter never gave up")
+			}
+			if (tt.retry != 0 && *calls != tt.wantCalls) || *calls < tt.wantCalls {
+				t.Errorf("gave up after %d attempts, want %d", *calls, tt.wantCalls)
+			}
+			if lasted := time.Since(start); lasted < tt.deadline {
+				t.Errorf("gave up after %s, want after the deadline %s", lasted, tt.deadline)
+			}
+			if st := r.Status.Get(); st.State != constant.ReporterStateStopped || st.LastError == "" {
+				t.Errorf("got state %s with error %q, want stopped with error", st.State, st.LastError)
+			}
+		})
+	}
+}
+
+func TestReporterReportReset(t *testing.T) {
+	// every stream connects and then breaks, the backoff is reset only if the streams last long enough
+	tests := []struct {
+		name       string
+		resetAfter time.Duration
+		wantGiveUp bool
+	}{
+		{name: "stable streams", resetAfter: 0},
+		{name: "short streams", resetAfter: time.Hour, wantGiveUp: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			failures := []int{}
+			r, calls := newTestReporter(func(r *Reporter) error {
+				failures = append(failures, r.Status.Get().Failures)
+				r.Status.SetConnected()
+				if len(failures) == 10 {
+					cancel()
+				}
+				return errors.New("stream broken")
+			})
+			r.RepRetry = 2
+			r.resetAfter = tt.resetAfter
+			err := r.report(ctx)
+			if (err != nil) != tt.wantGiveUp {
+				t.Fatalf("got error %v after %d attempts, want giving up %v", err, *calls, tt.wantGiveUp)
+			}
+			want := []int{0, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+			if tt.wantGiveUp {
+				want = []int{0, 1, 2}
+			}
+			if !reflect.DeepEqual(failures, want) {
+				t.Errorf("got failures %v before each attempt, want %v", failures, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package report
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checkCacheSize keeps the cache bounded, the cache is spilled to disk if it is full
+// while center is unreachable, or is transmitted before next tick otherwise.
+// The cache is taken out before spilling, so the file I/O never blocks others from the cache.
+func (r *Reporter) checkCacheSize() {
+	if r.MaxCacheSize <= 0 {
+		return
+	}
+	r.repCache.Lock()
+	if len(r.repCache.Data) < r.MaxCacheSize {
+		r.repCache.Unlock()
+		return
+	}
+	if atomic.LoadInt32(&r.connected) == 1 {
+		r.repCache.Unlock()
+		select {
+		case r.flushCh <- struct{}{}:
+		default:
+		}
+		return
+	}
+	records := make([]*entity.TrafficRecord, 0, len(r.repCache.Data))
+	for _, v := range r.repCache.Data {
+		records = append(records, v)
+	}
+	r.repCache.Init()
+	r.repCache.Unlock()
+
+	logrus.Warnf("cache is full while center is unreachable, spilling %d records to disk", len(records))
+	if err := r.spill(records); err != nil {
+		logrus.Errorf("failed to spill cache, keep records in memory, detail: %s", err)
+		r.repCache.Lock()
+		r.mergeCache(records)
+		r.repCache.Unlock()
+	}
+}
+
+// spillUnsent spills the cache and all the pending batches to disk, so they are not lost once reporter stops
+func (r *Reporter) spillUnsent() {
+	r.repCache.Lock()
+	records := make([]*entity.TrafficRecord, 0, len(r.repCache.Data))
+	for _, v := range r.repCache.Data {
+		records = append(records, v)
+	}
+	r.repCache.Init()
+	r.repCache.Unlock()
+
+	r.pendingMtx.Lock()
+	seqs := make([]uint64, 0, len(r.pending))
+	for seq := range r.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] < seqs[j]
+	})
+	pending := make([]*entity.TrafficRecord, 0)
+	for _, seq := range seqs {
+		for _, req := range r.pending[seq].batch.Records {
+			pending = append(pending, req.ToTrafficRecord())
+		}
+		delete(r.pending, seq)
+	}
+	r.pendingMtx.Unlock()
+
+	records = append(pending, records...)
+	if len(records) == 0 {
+		return
+	}
+	logrus.Infof("reporter stopped, spilling %d unsent records to disk", len(records))
+	if err := r.spill(records); err != nil {
+		logrus.Errorf("failed to spill unsent records, %d records dropped, detail: %s", len(records), err)
+	}
+}
+
+// spill writes the records to a new spill file in DumpDir, in JSON lines.
+// The records are written to a temporary file and synced, then renamed as the spill file, so they are on disk
+// once they count as spilled, and a spill file is never loaded partially.
+func (r *Reporter) spill(records []*entity.TrafficRecord) error {
+	cnt := atomic.AddUint64(&r.spillCnt, 1)
+	filename := fmt.Sprintf("%s%s_%d%s", constant.SpillFilePrefix,
+		time.Now().UTC().Format(constant.ISO8601CapFileFormat), cnt, constant.SpillFileSuffix)
+	filepath := path.Join(r.DumpDir, filename)
+	tmpPath := filepath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	err = writeSpillFile(f, records)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeSpillFile writes the records to f in JSON lines, and syncs it to disk
+func writeSpillFile(f *os.File, records []*entity.TrafficRecord) error {
+	w := bufio.NewWriter(f)
+	for _, record := range records {
+		str, err := record.ToJSONString()
+		if err != nil {
+			logrus.Warnf("failed to parse record to JSON string: %v", record)
+			continue
+		}
+		if _, err := w.WriteString(str + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// loadSpilled moves the records in spill files back to pending batches, one file at a time
+// while there is room for pending batches, the loaded spill file is removed
+func (r *Reporter) loadSpilled() {
+	files, err := ioutil.ReadDir(r.DumpDir)
+	if err != nil {
+		logrus.Warnf("failed to list dump directory %s, detail: %s", r.DumpDir, err)
+		return
+	}
+	filenames := make([]string, 0)
+	for _, fi := range files {
+		if strings.HasPrefix(fi.Name(), constant.SpillFilePrefix) && strings.HasSuffix(fi.Name(), constant.SpillFileSuffix) {
+			filenames = append(filenames, fi.Name())
+		}
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		r.pendingMtx.Lock()
+		full := len(r.pending) >= constant.ProbeMaxPendingBatches/2
+		r.pendingMtx.Unlock()
+		if full {
+			return
+		}
+
+		filepath := path.Join(r.DumpDir, filename)
+		records, err := readSpillFile(filepath)
+		if err != nil {
+			logrus.Errorf("failed to read spill file %s, detail: %s", filepath, err)
+			continue
+		}
+		logrus.Infof("loading %d spilled records from %s", len(records), filepath)
+		r.addPending(records)
+		if err := os.Remove(filepath); err != nil {
+			logrus.Warnf("failed to remove spill file %s, detail: %s", filepath, err)
+		}
+	}
+}
+
+func readSpillFile(filepath string) ([]*entity.TrafficRecord, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make([]*entity.TrafficRecord, 0)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var record entity.TrafficRecord
+		if err := json.Unmarshal(sc.Bytes(), &record); err != nil {
+			logrus.Warnf("get invalid line in spill file %s, with: %s", filepath, sc.Text())
+			continue
+		}
+		ret = append(ret, &record)
+	}
+	return ret, sc.Err()
+}
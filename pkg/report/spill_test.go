@@ -0,0 +1,104 @@
+package report
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func newRecords(n int) []*entity.TrafficRecord {
+	ret := make([]*entity.TrafficRecord, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, &entity.TrafficRecord{
+			Timestamp: fixtureEpoch,
+			ProbeIP:   "10.0.0.1",
+			SrcIP:     "10.0.0.1",
+			DstIP:     fmt.Sprintf("10.1.%d.%d", i/256, i%256),
+			Size:      60,
+			Protocol:  "tcp",
+			DstPort:   80,
+			Direction: "egress",
+			Packets:   1,
+		})
+	}
+	return ret
+}
+
+// spilledRecords reads all the spill files in dir
+func spilledRecords(t *testing.T, dir string) []*entity.TrafficRecord {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list %s: %s", dir, err)
+	}
+	ret := make([]*entity.TrafficRecord, 0)
+	for _, fi := range files {
+		if !strings.HasPrefix(fi.Name(), constant.SpillFilePrefix) || !strings.HasSuffix(fi.Name(), constant.SpillFileSuffix) {
+			t.Errorf("unexpected file %s left in dump directory", fi.Name())
+			continue
+		}
+		records, err := readSpillFile(path.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatalf("failed to read spill file %s: %s", fi.Name(), err)
+		}
+		ret = append(ret, records...)
+	}
+	return ret
+}
+
+func TestSpill(t *testing.T) {
+	tests := []struct {
+		name        string
+		cached      int // records in cache
+		pending     int // records in pending batches
+		wantPending int // batches left pending after spilling the overflow
+		wantSpilled int // records spilled once the overflow is taken out
+	}{
+		{name: "nothing to spill", wantPending: 0, wantSpilled: 0},
+		{name: "pending under limit", cached: 10, pending: 3 * constant.ProbeTransmitBatchSize, wantPending: 3, wantSpilled: 0},
+		{
+			name:        "pending over limit",
+			cached:      10,
+			pending:     (constant.ProbeMaxPendingBatches + 2) * constant.ProbeTransmitBatchSize,
+			wantPending: constant.ProbeMaxPendingBatches,
+			wantSpilled: 2 * constant.ProbeTransmitBatchSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "wakizashi-spill")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			r := &Reporter{DumpDir: dir}
+			r.Init()
+			r.addPending(newRecords(tt.pending))
+			if n := r.pendingCount(); n != tt.wantPending {
+				t.Errorf("%d batches pending, want %d", n, tt.wantPending)
+			}
+			if n := len(spilledRecords(t, dir)); n != tt.wantSpilled {
+				t.Errorf("%d records spilled of overflow, want %d", n, tt.wantSpilled)
+			}
+
+			r.repCache.Lock()
+			r.mergeCache(newRecords(tt.cached))
+			r.repCache.Unlock()
+			r.spillUnsent()
+			if n := r.pendingCount(); n != 0 {
+				t.Errorf("%d batches pending after spilling unsent records, want 0", n)
+			}
+			if n := len(r.repCache.Data); n != 0 {
+				t.Errorf("%d records cached after spilling unsent records, want 0", n)
+			}
+			if n := len(spilledRecords(t, dir)); n != tt.pending+tt.cached {
+				t.Errorf("%d records spilled in total, want %d", n, tt.pending+tt.cached)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package message
+
+import "BlankZhu/wakizashi/pkg/entity"
+
+// NewTransmitRequest convert the traffic record to TransmitRequest
+func NewTransmitRequest(record *entity.TrafficRecord) *TransmitRequest {
+	return &TransmitRequest{
+		Timestamp: uint64(record.Timestamp), // FIXME: potential casting error here
+		SrcIP:     record.SrcIP,
+		DstIP:     record.DstIP,
+		Size:      record.Size,
+		PodIP:     record.ProbeIP,
+		Protocol:  record.Protocol,
+		SrcPort:   uint32(record.SrcPort),
+		DstPort:   uint32(record.DstPort),
+		Direction: record.Direction,
+		Packets:   record.Packets,
+	}
+}
+
+// ToTrafficRecord convert the TransmitRequest to traffic record
+func (x *TransmitRequest) ToTrafficRecord() *entity.TrafficRecord {
+	return &entity.TrafficRecord{
+		Timestamp: int64(x.Timestamp),
+		ProbeIP:   x.PodIP,
+		SrcIP:     x.SrcIP,
+		DstIP:     x.DstIP,
+		Size:      x.Size,
+		Protocol:  x.Protocol,
+		SrcPort:   uint16(x.SrcPort),
+		DstPort:   uint16(x.DstPort),
+		Direction: x.Direction,
+		Packets:   x.Packets,
+	}
+}
+
+// NewCaptureStats convert the capture statistics to CaptureStats message
+func NewCaptureStats(stats entity.CaptureStats) *CaptureStats {
+	return &CaptureStats{
+		Iface:          stats.Iface,
+		Packets:        stats.Packets,
+		Drops:          stats.Drops,
+		DecodeFailures: stats.DecodeFailures,
+		ReadErrors:     stats.ReadErrors,
+		Bytes:          stats.Bytes,
+	}
+}
+
+// ToCaptureStats convert the CaptureStats message to capture statistics
+func (x *CaptureStats) ToCaptureStats() entity.CaptureStats {
+	return entity.CaptureStats{
+		Iface:          x.Iface,
+		Packets:        x.Packets,
+		Drops:          x.Drops,
+		DecodeFailures: x.DecodeFailures,
+		ReadErrors:     x.ReadErrors,
+		Bytes:          x.Bytes,
+	}
+}
+
+// NewProbeInfo convert the identity of probe to ProbeInfo message
+func NewProbeInfo(info *entity.ProbeInfo) *ProbeInfo {
+	return &ProbeInfo{
+		Id:        info.ID,
+		Hostname:  info.Hostname,
+		Node:      info.Node,
+		Pod:       info.Pod,
+		Namespace: info.Namespace,
+		Labels:    info.Labels,
+	}
+}
+
+// ToProbeInfo convert the ProbeInfo message to identity of probe
+func (x *ProbeInfo) ToProbeInfo() *entity.ProbeInfo {
+	return &entity.ProbeInfo{
+		ID:        x.Id,
+		Hostname:  x.Hostname,
+		Node:      x.Node,
+		Pod:       x.Pod,
+		Namespace: x.Namespace,
+		Labels:    x.Labels,
+	}
+}
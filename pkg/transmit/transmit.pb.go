@@ -7,11 +7,11 @@
 package transmit
 
 import (
+	message "BlankZhu/wakizashi/pkg/transmit/message"
 	proto "github.com/golang/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
-	sync "sync"
 )
 
 const (
@@ -25,188 +25,38 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
-type TransmitRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Timestamp uint64 `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	SrcIP     string `protobuf:"bytes,2,opt,name=srcIP,proto3" json:"srcIP,omitempty"`
-	DstIP     string `protobuf:"bytes,3,opt,name=dstIP,proto3" json:"dstIP,omitempty"`
-	PodIP     string `protobuf:"bytes,4,opt,name=podIP,proto3" json:"podIP,omitempty"`
-	Size      uint64 `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
-}
-
-func (x *TransmitRequest) Reset() {
-	*x = TransmitRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transmit_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *TransmitRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*TransmitRequest) ProtoMessage() {}
-
-func (x *TransmitRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_transmit_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use TransmitRequest.ProtoReflect.Descriptor instead.
-func (*TransmitRequest) Descriptor() ([]byte, []int) {
-	return file_transmit_proto_rawDescGZIP(), []int{0}
-}
-
-func (x *TransmitRequest) GetTimestamp() uint64 {
-	if x != nil {
-		return x.Timestamp
-	}
-	return 0
-}
-
-func (x *TransmitRequest) GetSrcIP() string {
-	if x != nil {
-		return x.SrcIP
-	}
-	return ""
-}
-
-func (x *TransmitRequest) GetDstIP() string {
-	if x != nil {
-		return x.DstIP
-	}
-	return ""
-}
-
-func (x *TransmitRequest) GetPodIP() string {
-	if x != nil {
-		return x.PodIP
-	}
-	return ""
-}
-
-func (x *TransmitRequest) GetSize() uint64 {
-	if x != nil {
-		return x.Size
-	}
-	return 0
-}
-
-type TransmitReply struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Res    bool   `protobuf:"varint,1,opt,name=res,proto3" json:"res,omitempty"`
-	Detail string `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
-}
-
-func (x *TransmitReply) Reset() {
-	*x = TransmitReply{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transmit_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *TransmitReply) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*TransmitReply) ProtoMessage() {}
-
-func (x *TransmitReply) ProtoReflect() protoreflect.Message {
-	mi := &file_transmit_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use TransmitReply.ProtoReflect.Descriptor instead.
-func (*TransmitReply) Descriptor() ([]byte, []int) {
-	return file_transmit_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *TransmitReply) GetRes() bool {
-	if x != nil {
-		return x.Res
-	}
-	return false
-}
-
-func (x *TransmitReply) GetDetail() string {
-	if x != nil {
-		return x.Detail
-	}
-	return ""
-}
-
 var File_transmit_proto protoreflect.FileDescriptor
 
 var file_transmit_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x22, 0x85, 0x01, 0x0a, 0x0f, 0x54,
-	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c,
-	0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x14, 0x0a, 0x05,
-	0x73, 0x72, 0x63, 0x49, 0x50, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x72, 0x63,
-	0x49, 0x50, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x73, 0x74, 0x49, 0x50, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x05, 0x64, 0x73, 0x74, 0x49, 0x50, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x6f, 0x64, 0x49,
-	0x50, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x6f, 0x64, 0x49, 0x50, 0x12, 0x12,
-	0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69,
-	0x7a, 0x65, 0x22, 0x39, 0x0a, 0x0d, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x52, 0x65,
-	0x70, 0x6c, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x03, 0x72, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x32, 0x4e, 0x0a,
-	0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x12, 0x42, 0x0a, 0x08, 0x74, 0x72, 0x61,
-	0x6e, 0x73, 0x6d, 0x69, 0x74, 0x12, 0x19, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74,
-	0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x1a, 0x17, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x6e,
-	0x73, 0x6d, 0x69, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x28, 0x01, 0x42, 0x0c, 0x5a,
-	0x0a, 0x2e, 0x3b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
-}
-
-var (
-	file_transmit_proto_rawDescOnce sync.Once
-	file_transmit_proto_rawDescData = file_transmit_proto_rawDesc
-)
-
-func file_transmit_proto_rawDescGZIP() []byte {
-	file_transmit_proto_rawDescOnce.Do(func() {
-		file_transmit_proto_rawDescData = protoimpl.X.CompressGZIP(file_transmit_proto_rawDescData)
-	})
-	return file_transmit_proto_rawDescData
+	0x12, 0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x1a, 0x15, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x32, 0x95, 0x01, 0x0a, 0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x12, 0x42,
+	0x0a, 0x08, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x12, 0x19, 0x2e, 0x74, 0x72, 0x61,
+	0x6e, 0x73, 0x6d, 0x69, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74,
+	0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
+	0x28, 0x01, 0x12, 0x45, 0x0a, 0x0d, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x12, 0x17, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x2e, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x1a, 0x15, 0x2e, 0x74,
+	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74,
+	0x41, 0x63, 0x6b, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x3b, 0x74,
+	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
-var file_transmit_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_transmit_proto_goTypes = []interface{}{
-	(*TransmitRequest)(nil), // 0: transmit.TransmitRequest
-	(*TransmitReply)(nil),   // 1: transmit.TransmitReply
+	(*message.TransmitRequest)(nil), // 0: transmit.TransmitRequest
+	(*message.TransmitBatch)(nil),   // 1: transmit.TransmitBatch
+	(*message.TransmitReply)(nil),   // 2: transmit.TransmitReply
+	(*message.TransmitAck)(nil),     // 3: transmit.TransmitAck
 }
 var file_transmit_proto_depIdxs = []int32{
 	0, // 0: transmit.transmit.transmit:input_type -> transmit.TransmitRequest
-	1, // 1: transmit.transmit.transmit:output_type -> transmit.TransmitReply
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	1, // 1: transmit.transmit.transmitBatch:input_type -> transmit.TransmitBatch
+	2, // 2: transmit.transmit.transmit:output_type -> transmit.TransmitReply
+	3, // 3: transmit.transmit.transmitBatch:output_type -> transmit.TransmitAck
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -217,45 +67,18 @@ func file_transmit_proto_init() {
 	if File_transmit_proto != nil {
 		return
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_transmit_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TransmitRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transmit_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TransmitReply); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_transmit_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   0,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_transmit_proto_goTypes,
 		DependencyIndexes: file_transmit_proto_depIdxs,
-		MessageInfos:      file_transmit_proto_msgTypes,
 	}.Build()
 	File_transmit_proto = out.File
 	file_transmit_proto_rawDesc = nil
@@ -3,6 +3,7 @@
 package transmit
 
 import (
+	message "BlankZhu/wakizashi/pkg/transmit/message"
 	context "context"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
@@ -18,6 +19,8 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TransmitClient interface {
 	Transmit(ctx context.Context, opts ...grpc.CallOption) (Transmit_TransmitClient, error)
+	// transmitBatch uploads batches of traffic data, each batch is acknowledged once the center persisted it
+	TransmitBatch(ctx context.Context, opts ...grpc.CallOption) (Transmit_TransmitBatchClient, error)
 }
 
 type transmitClient struct {
@@ -38,8 +41,8 @@ func (c *transmitClient) Transmit(ctx context.Context, opts ...grpc.CallOption)
 }
 
 type Transmit_TransmitClient interface {
-	Send(*TransmitRequest) error
-	CloseAndRecv() (*TransmitReply, error)
+	Send(*message.TransmitRequest) error
+	CloseAndRecv() (*message.TransmitReply, error)
 	grpc.ClientStream
 }
 
@@ -47,15 +50,46 @@ type transmitTransmitClient struct {
 	grpc.ClientStream
 }
 
-func (x *transmitTransmitClient) Send(m *TransmitRequest) error {
+func (x *transmitTransmitClient) Send(m *message.TransmitRequest) error {
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *transmitTransmitClient) CloseAndRecv() (*TransmitReply, error) {
+func (x *transmitTransmitClient) CloseAndRecv() (*message.TransmitReply, error) {
 	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
-	m := new(TransmitReply)
+	m := new(message.TransmitReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *transmitClient) TransmitBatch(ctx context.Context, opts ...grpc.CallOption) (Transmit_TransmitBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Transmit_serviceDesc.Streams[1], "/transmit.transmit/transmitBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &transmitTransmitBatchClient{stream}
+	return x, nil
+}
+
+type Transmit_TransmitBatchClient interface {
+	Send(*message.TransmitBatch) error
+	Recv() (*message.TransmitAck, error)
+	grpc.ClientStream
+}
+
+type transmitTransmitBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *transmitTransmitBatchClient) Send(m *message.TransmitBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *transmitTransmitBatchClient) Recv() (*message.TransmitAck, error) {
+	m := new(message.TransmitAck)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
@@ -67,6 +101,8 @@ func (x *transmitTransmitClient) CloseAndRecv() (*TransmitReply, error) {
 // for forward compatibility
 type TransmitServer interface {
 	Transmit(Transmit_TransmitServer) error
+	// transmitBatch uploads batches of traffic data, each batch is acknowledged once the center persisted it
+	TransmitBatch(Transmit_TransmitBatchServer) error
 	mustEmbedUnimplementedTransmitServer()
 }
 
@@ -77,6 +113,9 @@ type UnimplementedTransmitServer struct {
 func (UnimplementedTransmitServer) Transmit(Transmit_TransmitServer) error {
 	return status.Errorf(codes.Unimplemented, "method Transmit not implemented")
 }
+func (UnimplementedTransmitServer) TransmitBatch(Transmit_TransmitBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method TransmitBatch not implemented")
+}
 func (UnimplementedTransmitServer) mustEmbedUnimplementedTransmitServer() {}
 
 // UnsafeTransmitServer may be embedded to opt out of forward compatibility for this service.
@@ -95,8 +134,8 @@ func _Transmit_Transmit_Handler(srv interface{}, stream grpc.ServerStream) error
 }
 
 type Transmit_TransmitServer interface {
-	SendAndClose(*TransmitReply) error
-	Recv() (*TransmitRequest, error)
+	SendAndClose(*message.TransmitReply) error
+	Recv() (*message.TransmitRequest, error)
 	grpc.ServerStream
 }
 
@@ -104,12 +143,38 @@ type transmitTransmitServer struct {
 	grpc.ServerStream
 }
 
-func (x *transmitTransmitServer) SendAndClose(m *TransmitReply) error {
+func (x *transmitTransmitServer) SendAndClose(m *message.TransmitReply) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *transmitTransmitServer) Recv() (*TransmitRequest, error) {
-	m := new(TransmitRequest)
+func (x *transmitTransmitServer) Recv() (*message.TransmitRequest, error) {
+	m := new(message.TransmitRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Transmit_TransmitBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransmitServer).TransmitBatch(&transmitTransmitBatchServer{stream})
+}
+
+type Transmit_TransmitBatchServer interface {
+	Send(*message.TransmitAck) error
+	Recv() (*message.TransmitBatch, error)
+	grpc.ServerStream
+}
+
+type transmitTransmitBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *transmitTransmitBatchServer) Send(m *message.TransmitAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *transmitTransmitBatchServer) Recv() (*message.TransmitBatch, error) {
+	m := new(message.TransmitBatch)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
@@ -126,6 +191,12 @@ var _Transmit_serviceDesc = grpc.ServiceDesc{
 			Handler:       _Transmit_Transmit_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "transmitBatch",
+			Handler:       _Transmit_TransmitBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "transmit.proto",
 }
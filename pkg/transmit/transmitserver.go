@@ -1,29 +1,59 @@
 package transmit
 
 import (
+	"BlankZhu/wakizashi/pkg/auth"
+	"BlankZhu/wakizashi/pkg/backend"
+	"BlankZhu/wakizashi/pkg/entity"
+	"BlankZhu/wakizashi/pkg/transmit/message"
+	"fmt"
 	"io"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/peer"
 )
 
+// recordOrigin is where the records on a stream come from, attached to them before written
+type recordOrigin struct {
+	tenant string            // tenant resolved from the token of probe
+	probe  *entity.ProbeInfo // identity of probe in handshake, nil if not identified
+}
+
+func (o *recordOrigin) attach(record *entity.TrafficRecord) {
+	record.Tenant = o.tenant
+	if o.probe != nil {
+		record.SetProbeInfo(o.probe)
+	}
+}
+
 // CenterServer implements UnimplementedTransmitServer
 type CenterServer struct {
 	UnimplementedTransmitServer
-	IPSet map[string]struct{}
+	IPSet   map[string]struct{}
+	Backend backend.DataBackend // where the records are written to, records failed to write are spooled by it
+}
+
+// Transmit implements TransmitServer, see HandleRequest
+func (cs *CenterServer) Transmit(stream Transmit_TransmitServer) error {
+	return cs.HandleRequest(stream)
+}
+
+// TransmitBatch implements TransmitServer, see HandleBatchRequest
+func (cs *CenterServer) TransmitBatch(stream Transmit_TransmitBatchServer) error {
+	return cs.HandleBatchRequest(stream)
 }
 
 // HandleRequest handles the grpc requests from probe
 func (cs *CenterServer) HandleRequest(stream Transmit_TransmitServer) error {
+	tenant := auth.TenantFromContext(stream.Context())
 	if peer, ok := peer.FromContext(stream.Context()); ok {
-		logrus.Infof("receiving traffic data transmit request from: %s", peer.Addr.String())
+		logrus.Infof("receiving traffic data transmit request from: %s, tenant: %s", peer.Addr.String(), tenant)
 	}
 
 	for {
 		req, err := stream.Recv()
 		if err == io.EOF {
-			return stream.SendAndClose(&TransmitReply{
-				Res: true,
+			return stream.SendAndClose(&message.TransmitReply{
+				Res:    true,
 				Detail: "connection close",
 			})
 		}
@@ -33,19 +63,108 @@ func (cs *CenterServer) HandleRequest(stream Transmit_TransmitServer) error {
 		}
 
 		// filters those traffic between probe and center
-		_, isFromCenter := cs.IPSet[req.SrcIP]
-		_, isToCenter := cs.IPSet[req.DstIP]
-		if isFromCenter || isToCenter {
+		if cs.isCenterTraffic(req) {
+			continue
+		}
+
+		cs.handleTransmitRequest(req, tenant)
+	}
+}
+
+// HandleBatchRequest handles the batched grpc requests from probe,
+// every batch is acknowledged after being written to data backend or its recovery
+func (cs *CenterServer) HandleBatchRequest(stream Transmit_TransmitBatchServer) error {
+	probeAddr := "unknown"
+	origin := recordOrigin{tenant: auth.TenantFromContext(stream.Context())}
+	lastStats := make(map[string]entity.CaptureStats) // capture statistics last reported on the stream, by network device
+	if peer, ok := peer.FromContext(stream.Context()); ok {
+		probeAddr = peer.Addr.String()
+		logrus.Infof("receiving batched traffic data transmit request from: %s, tenant: %s", probeAddr, origin.tenant)
+	}
+
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			logrus.Errorf("transmit batch request error, detail: %s", err)
+			return err
+		}
+		if batch.Probe != nil {
+			origin.probe = batch.Probe.ToProbeInfo()
+			logrus.Infof("probe %s identified: %+v", probeAddr, *origin.probe)
+		}
+		cs.logCaptureStats(probeAddr, batch.Stats, lastStats)
+
+		ack := &message.TransmitAck{
+			Seq: batch.Seq,
+			Res: true,
+		}
+		if err := cs.handleTransmitBatch(batch, &origin); err != nil {
+			logrus.Errorf("failed to persist batch %d, detail: %s", batch.Seq, err)
+			ack.Res = false
+			ack.Detail = err.Error()
+		}
+		if err := stream.Send(ack); err != nil {
+			logrus.Errorf("failed to acknowledge batch %d, detail: %s", batch.Seq, err)
+			return err
+		}
+	}
+}
+
+func (cs *CenterServer) handleTransmitRequest(req *message.TransmitRequest, tenant string) {
+	record := req.ToTrafficRecord()
+	record.Tenant = tenant
+
+	if cs.Backend == nil {
+		logrus.Errorf("data backend not initialized, record dropped")
+		return
+	}
+	if err := cs.Backend.Write(record); err != nil {
+		logrus.Errorf("failed to write record to data backend, record dropped, detail: %s", err)
+	}
+}
+
+// handleTransmitBatch writes the batch to data backend with its origin attached, error is returned if it is neither
+// written nor spooled
+func (cs *CenterServer) handleTransmitBatch(batch *message.TransmitBatch, origin *recordOrigin) error {
+	records := make([]*entity.TrafficRecord, 0, len(batch.Records))
+	for _, req := range batch.Records {
+		if cs.isCenterTraffic(req) {
 			continue
 		}
+		record := req.ToTrafficRecord()
+		origin.attach(record)
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil
+	}
 
-		cs.handleTransmitRequest(req)
+	if cs.Backend == nil {
+		return fmt.Errorf("data backend not initialized")
 	}
+	return cs.Backend.WriteBatch(records)
 }
 
-func (cs *CenterServer) handleTransmitRequest(req *TransmitRequest) {
-	// recover := recovery.Get()
-	// ts := time.Unix(int64(req.Timestamp), 0)
+// logCaptureStats logs the capture statistics reported by probe, warns if the traffic is undercounted since
+// last reported in last, otherwise logs at debug level as the statistics are reported every tick
+func (cs *CenterServer) logCaptureStats(probeAddr string, stats []*message.CaptureStats, last map[string]entity.CaptureStats) {
+	for _, v := range stats {
+		st := v.ToCaptureStats()
+		prev := last[st.Iface]
+		last[st.Iface] = st
+		if st.Drops > prev.Drops || st.DecodeFailures > prev.DecodeFailures || st.ReadErrors > prev.ReadErrors {
+			logrus.Warnf("probe %s might undercount traffic on %s: %+v", probeAddr, st.Iface, st)
+			continue
+		}
+		logrus.Debugf("capture statistics of probe %s on %s: %+v", probeAddr, st.Iface, st)
+	}
+}
 
-	// TODO: add databack end
+func (cs *CenterServer) isCenterTraffic(req *message.TransmitRequest) bool {
+	_, isFromCenter := cs.IPSet[req.SrcIP]
+	_, isToCenter := cs.IPSet[req.DstIP]
+	return isFromCenter || isToCenter
 }
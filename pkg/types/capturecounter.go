@@ -0,0 +1,59 @@
+package types
+
+import (
+	"BlankZhu/wakizashi/pkg/entity"
+	"sync/atomic"
+)
+
+// CaptureCounter counts the statistics of capturing on a network device, thread-safe
+type CaptureCounter struct {
+	// accessed atomically, keep them first for 64-bit alignment
+	packets        uint64
+	drops          uint64
+	decodeFailures uint64
+	readErrors     uint64
+	bytes          uint64
+	Iface          string
+}
+
+// NewCaptureCounter return the counter of given network device
+func NewCaptureCounter(iface string) *CaptureCounter {
+	return &CaptureCounter{Iface: iface}
+}
+
+// AddPackets adds the count of packets read
+func (cc *CaptureCounter) AddPackets(n uint64) {
+	atomic.AddUint64(&cc.packets, n)
+}
+
+// AddDrops adds the count of packets dropped by kernel
+func (cc *CaptureCounter) AddDrops(n uint64) {
+	atomic.AddUint64(&cc.drops, n)
+}
+
+// AddDecodeFailures adds the count of packets failed to decode
+func (cc *CaptureCounter) AddDecodeFailures(n uint64) {
+	atomic.AddUint64(&cc.decodeFailures, n)
+}
+
+// AddReadErrors adds the count of read errors
+func (cc *CaptureCounter) AddReadErrors(n uint64) {
+	atomic.AddUint64(&cc.readErrors, n)
+}
+
+// AddBytes adds the size of traffic accounted
+func (cc *CaptureCounter) AddBytes(n uint64) {
+	atomic.AddUint64(&cc.bytes, n)
+}
+
+// Get return a snapshot of the statistics
+func (cc *CaptureCounter) Get() entity.CaptureStats {
+	return entity.CaptureStats{
+		Iface:          cc.Iface,
+		Packets:        atomic.LoadUint64(&cc.packets),
+		Drops:          atomic.LoadUint64(&cc.drops),
+		DecodeFailures: atomic.LoadUint64(&cc.decodeFailures),
+		ReadErrors:     atomic.LoadUint64(&cc.readErrors),
+		Bytes:          atomic.LoadUint64(&cc.bytes),
+	}
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"BlankZhu/wakizashi/pkg/constant"
+	"BlankZhu/wakizashi/pkg/entity"
+	"sync"
+	"time"
+)
+
+// ReporterStatus keeps the state of reporter's connection to center, thread-safe
+type ReporterStatus struct {
+	mu    sync.Mutex
+	stats entity.ReporterStats
+}
+
+// NewReporterStatus return the status of a reporter connecting to center
+func NewReporterStatus() *ReporterStatus {
+	return &ReporterStatus{stats: entity.ReporterStats{State: constant.ReporterStateConnecting}}
+}
+
+// SetConnecting marks the reporter connecting to center
+func (rs *ReporterStatus) SetConnecting() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stats.State = constant.ReporterStateConnecting
+	rs.stats.ConnectedSince = 0
+	rs.stats.RetryAt = 0
+}
+
+// SetConnected marks the reporter connected to center, the failures are kept until the reporter resets its backoff
+func (rs *ReporterStatus) SetConnected() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stats.State = constant.ReporterStateConnected
+	rs.stats.ConnectedSince = time.Now().Unix()
+	rs.stats.RetryAt = 0
+}
+
+// SetBackoff marks the reporter waiting until retryAt after the failures
+func (rs *ReporterStatus) SetBackoff(err error, failures int, retryAt time.Time) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stats.State = constant.ReporterStateBackoff
+	rs.stats.ConnectedSince = 0
+	rs.stats.Failures = failures
+	rs.stats.RetryAt = retryAt.Unix()
+	rs.setError(err)
+}
+
+// SetStopped marks the reporter stopped, err is nil if it is not giving up
+func (rs *ReporterStatus) SetStopped(err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stats.State = constant.ReporterStateStopped
+	rs.stats.ConnectedSince = 0
+	rs.stats.RetryAt = 0
+	if err != nil {
+		rs.setError(err)
+	}
+}
+
+// Get return a snapshot of the status
+func (rs *ReporterStatus) Get() entity.ReporterStats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.stats
+}
+
+func (rs *ReporterStatus) setError(err error) {
+	if err == nil {
+		return
+	}
+	rs.stats.LastError = err.Error()
+	rs.stats.LastErrorTime = time.Now().Unix()
+}
@@ -13,7 +13,7 @@ func GetIPSetFromNetworkInterface(dev *net.Interface) map[string]struct{} {
 		return ret
 	}
 	for _, addr := range addrs {
-		ret[strings.Split(addr.String(), "/")[0]] = struct{}{}
+		ret[addrToIP(addr)] = struct{}{}
 	}
 	return ret
 }
@@ -28,9 +28,30 @@ func GetIPSetFromNetworkInterfaces(devs []net.Interface) map[string]struct{} {
 			return ret
 		}
 		for _, addr := range addrs {
-			ret[strings.Split(addr.String(), "/")[0]] = struct{}{}
+			ret[addrToIP(addr)] = struct{}{}
 		}
 	}
 	return ret
 
 }
+
+// NormalizeIP return the canonical form of given IPv4 or IPv6 address,
+// so that the same address is always represented by the same string, return "" if invalid
+func NormalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+// addrToIP return the canonical IP string of given interface address
+func addrToIP(addr net.Addr) string {
+	switch v := addr.(type) {
+	case *net.IPNet:
+		return v.IP.String()
+	case *net.IPAddr:
+		return v.IP.String()
+	}
+	return strings.Split(addr.String(), "/")[0]
+}